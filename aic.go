@@ -0,0 +1,225 @@
+// aic.go
+//
+// Alternating inference chains, the most general of the techniques, which take in the X-cycles and many of the wings.  A node of a
+// chain is a value in one square, or in two or three squares where a block crosses a row or column (a group), and is true if the value
+// is in one of them.  Two nodes are strongly linked if at least one must be true: the only two places left for a value in a row, column
+// or block, or the only two values left in a square.  They are weakly linked if at most one can be true: the same value in squares that
+// all see each other, or two values in the same square.  A chain that starts and ends with a strong link, alternating between strong and
+// weak links, proves that one of its two ends is true, so any value that sees both ends can be cleared.  A chain from a node back to
+// the same node shows that the node is true, if it starts and ends with a strong link, or false, if it starts and ends with a weak link.
+// Building every chain is expensive, so like aligned pair exclusion it is only done with -advanced.
+package main
+
+const alternatingInferenceChain technique = "alternating-inference-chain"
+
+func init() {
+	advancedTechniques = append(advancedTechniques, alternatingInferenceChain)
+}
+
+// aicNode is a node of an alternating inference chain: val in one or more of the squares marked in cells, which are also listed in pos.
+type aicNode struct {
+	val   squareVal
+	cells [9][9]bool
+	pos   []gridPos
+}
+
+type aicNodeKey struct {
+	val   squareVal
+	cells [9][9]bool
+}
+
+// aicGraph holds the nodes and links of an alternating inference chain search.  strong[n] and weak[n] list the nodes strongly and
+// weakly linked to node n; every strong link between nodes for the same value is also weak, and is listed in both.
+type aicGraph struct {
+	nodes  []*aicNode
+	index  map[aicNodeKey]int
+	strong [][]int
+	weak   [][]int
+}
+
+// node returns the index of the node for val in the squares ps, adding it if it is new.
+func (g *aicGraph) node(val squareVal, ps []gridPos) int {
+	var key aicNodeKey
+	key.val = val
+	for _, p := range ps {
+		key.cells[p.r][p.c] = true
+	}
+	if n, ok := g.index[key]; ok {
+		return n
+	}
+	g.nodes = append(g.nodes, &aicNode{val, key.cells, append([]gridPos(nil), ps...)})
+	g.strong = append(g.strong, nil)
+	g.weak = append(g.weak, nil)
+	g.index[key] = len(g.nodes) - 1
+	return len(g.nodes) - 1
+}
+
+// sees reports whether node b must be false if node a is true.
+func (g *aicGraph) sees(a, b int) bool {
+	na, nb := g.nodes[a], g.nodes[b]
+	if a == b {
+		return false
+	}
+	if na.val != nb.val {
+		// Two values in the same square.
+		return len(na.pos) == 1 && len(nb.pos) == 1 && na.pos[0] == nb.pos[0]
+	}
+	for _, p := range na.pos {
+		for _, q := range nb.pos {
+			if !seesSquare(p.r, p.c, q.r, q.c) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// buildAICGraph makes the nodes and links from the board as it stands.
+func buildAICGraph() *aicGraph {
+	g := &aicGraph{index: map[aicNodeKey]int{}}
+	for i := 0; i < 9; i++ {
+		for j := 0; j < 9; j++ {
+			if board[i][j].isFinal {
+				continue
+			}
+			var vals []int
+			board[i][j].possVal.each(func(val squareVal) {
+				vals = append(vals, g.node(val, []gridPos{{i, j}}))
+			})
+			if len(vals) == 2 {
+				g.strong[vals[0]] = append(g.strong[vals[0]], vals[1])
+				g.strong[vals[1]] = append(g.strong[vals[1]], vals[0])
+			}
+		}
+	}
+	var units [27][]gridPos
+	for k := 0; k < 9; k++ {
+		for l := 0; l < 9; l++ {
+			units[k] = append(units[k], gridPos{k, l})
+			units[9+k] = append(units[9+k], gridPos{l, k})
+		}
+		units[18+k] = blockSquares[k][:]
+	}
+	for u, unit := range units {
+		for val := one; val <= nine; val <<= 1 {
+			// A square finalized this round may not have cleared its value from the rest of the unit yet, so a unit where the value
+			// is already placed is left alone.
+			var places []gridPos
+			placed := false
+			for _, p := range unit {
+				if board[p.r][p.c].possVal.Has(val) {
+					places = append(places, p)
+					placed = placed || board[p.r][p.c].isFinal
+				}
+			}
+			if placed || len(places) < 2 {
+				continue
+			}
+			// Split the places in two, by square if there are only two, and otherwise into two groups: by block for a row or column,
+			// and by row and then by column for a block.  Each way the places fall into exactly two parts gives a strong link.
+			var splits [][2][]gridPos
+			if len(places) == 2 {
+				splits = append(splits, [2][]gridPos{{places[0]}, {places[1]}})
+			}
+			parts := []func(p gridPos) int{func(p gridPos) int { return blockOf[p.r][p.c] }}
+			if u >= 18 {
+				parts = []func(p gridPos) int{func(p gridPos) int { return p.r }, func(p gridPos) int { return p.c }}
+			}
+			for _, part := range parts {
+				first := part(places[0])
+				var a, b []gridPos
+				for _, p := range places {
+					if part(p) == first {
+						a = append(a, p)
+					} else if len(b) == 0 || part(p) == part(b[0]) {
+						b = append(b, p)
+					} else {
+						a = nil
+						break
+					}
+				}
+				if len(a) > 0 && len(b) > 0 && (len(a) > 1 || len(b) > 1) {
+					splits = append(splits, [2][]gridPos{a, b})
+				}
+			}
+			for _, s := range splits {
+				na, nb := g.node(val, s[0]), g.node(val, s[1])
+				g.strong[na] = append(g.strong[na], nb)
+				g.strong[nb] = append(g.strong[nb], na)
+			}
+		}
+	}
+	// Groups only come into a chain through their strong links, so the weak links are worked out once all the nodes are known.
+	for a := range g.nodes {
+		for b := range g.nodes {
+			if g.sees(a, b) {
+				g.weak[a] = append(g.weak[a], b)
+			}
+		}
+	}
+	return g
+}
+
+// maxChainLength is the most links a chain is followed for, set by -max-chain-length, or 0 for no limit.  A shorter limit finds fewer
+// chains, but takes less time on a board with many links.
+var maxChainLength int
+
+func checkAlternatingInferenceChains() {
+	g := buildAICGraph()
+	// A literal is a node being true (2n+1) or false (2n).  From a false node a strong link makes the next node true, and from a true
+	// node a weak link makes the next false, so the literals reached from one are those the chains from it prove.  They are reached
+	// shortest chain first, so stopping at maxChainLength links misses only the chains that are longer.
+	reach := func(start int) []bool {
+		seen := make([]bool, 2*len(g.nodes))
+		length := make([]int, 2*len(g.nodes))
+		seen[start] = true
+		queue := []int{start}
+		for len(queue) > 0 {
+			l := queue[0]
+			queue = queue[1:]
+			if maxChainLength > 0 && length[l] == maxChainLength {
+				continue
+			}
+			n, links, next := l/2, g.strong[l/2], 1
+			if l%2 == 1 {
+				links, next = g.weak[n], 0
+			}
+			for _, m := range links {
+				if t := 2*m + next; !seen[t] {
+					seen[t], length[t] = true, length[l]+1
+					queue = append(queue, t)
+				}
+			}
+		}
+		return seen
+	}
+	// The same value is often cleared by many chains, so the clears are gathered up and sent once at the end.
+	var clears [9][9]squareVal
+	for x, nx := range g.nodes {
+		// A chain that starts from x being false, and proves a node y true, shows that x or y is true; every value that sees both
+		// can be cleared.
+		fromFalse := reach(2 * x)
+		for y := range g.nodes {
+			// The links go both ways, so a chain from y being false proves x true just as well; each pair only needs looking at once.
+			if y < x || !fromFalse[2*y+1] {
+				continue
+			}
+			for _, z := range g.weak[x] {
+				if nz := g.nodes[z]; len(nz.pos) == 1 && z != y && (y == x || g.sees(y, z)) {
+					clears[nz.pos[0].r][nz.pos[0].c] = clears[nz.pos[0].r][nz.pos[0].c].Add(nz.val)
+				}
+			}
+		}
+		// A chain that starts from x being true, and proves it false, shows that it is false.
+		if len(nx.pos) == 1 && reach(2*x + 1)[2*x] {
+			clears[nx.pos[0].r][nx.pos[0].c] = clears[nx.pos[0].r][nx.pos[0].c].Add(nx.val)
+		}
+	}
+	for i := 0; i < 9; i++ {
+		for j := 0; j < 9; j++ {
+			if clears[i][j] != 0 {
+				clearIfPossible(clears[i][j], i, j, alternatingInferenceChain)
+			}
+		}
+	}
+}
@@ -0,0 +1,137 @@
+// ape.go
+//
+// Aligned pair exclusion, for puzzles that resist the wings and fish.  Two unsolved squares in the same row, column or block cannot hold
+// the same value, and between them cannot take two of the values of an almost locked set that they see: N unsolved squares of one unit
+// with only N+1 possible values left between them, which would be left N-1 values for N squares.  Every pair of values the two squares
+// could take is tried against every such set, and a value that cannot go with any value of the other square is cleared.  Trying every
+// pair of squares against every set is expensive, so it is only done with -advanced.
+package main
+
+import "math/bits"
+
+const alignedPairExclusion technique = "aligned-pair-exclusion"
+
+// maxALSSize is the most squares an almost locked set is looked for with, set by -max-als-size.  Larger sets are rarely needed, and their
+// number grows fast.
+var maxALSSize = 4
+
+// advanced is set by -advanced, to use the techniques in advancedTechniques as well as the rest.
+var advanced bool
+
+// advancedTechniques are the techniques too slow to use on every puzzle, which are only used with -advanced.
+var advancedTechniques = []technique{alignedPairExclusion}
+
+// almostLockedSet is a set of N unsolved squares in one unit with N+1 possible values between them.  sees[k][i][j] is set if square
+// i, j sees every square of the set where the value k+1 is possible, and so would rule it out of the set by taking it.
+type almostLockedSet struct {
+	values squareVal
+	sees   [9][9][9]bool
+}
+
+// findAlmostLockedSets returns the almost locked sets of up to maxALSSize squares in every row, column and block.  A set that lies in
+// both a line and a block is only returned once.
+func findAlmostLockedSets() (sets []*almostLockedSet) {
+	var units [27][]gridPos
+	for k := 0; k < 9; k++ {
+		for l := 0; l < 9; l++ {
+			units[k] = append(units[k], gridPos{k, l})
+			units[9+k] = append(units[9+k], gridPos{l, k})
+		}
+		units[18+k] = blockSquares[k][:]
+	}
+	found := map[[9][9]bool]bool{}
+	for _, unit := range units {
+		var open []gridPos
+		for _, p := range unit {
+			if !board[p.r][p.c].isFinal {
+				open = append(open, p)
+			}
+		}
+		for subset := 1; subset < 1<<len(open); subset++ {
+			n := bits.OnesCount(uint(subset))
+			if n > maxALSSize {
+				continue
+			}
+			var values squareVal
+			var in [9][9]bool
+			for k, p := range open {
+				if subset&(1<<k) != 0 {
+					values = values.Add(board[p.r][p.c].possVal)
+					in[p.r][p.c] = true
+				}
+			}
+			if values.Count() != n+1 || found[in] {
+				continue
+			}
+			found[in] = true
+			s := &almostLockedSet{values: values}
+			values.each(func(val squareVal) {
+				k := bits.TrailingZeros16(uint16(val))
+				for i := 0; i < 9; i++ {
+					for j := 0; j < 9; j++ {
+						s.sees[k][i][j] = true
+						for _, p := range open {
+							if in[p.r][p.c] && board[p.r][p.c].possVal.Has(val) && !seesSquare(i, j, p.r, p.c) {
+								s.sees[k][i][j] = false
+								break
+							}
+						}
+					}
+				}
+			})
+			sets = append(sets, s)
+		}
+	}
+	return
+}
+
+func checkAlignedPairs() {
+	sets := findAlmostLockedSets()
+	var open []gridPos
+	for i := 0; i < 9; i++ {
+		for j := 0; j < 9; j++ {
+			if !board[i][j].isFinal {
+				open = append(open, gridPos{i, j})
+			}
+		}
+	}
+	for ia, a := range open {
+		av := board[a.r][a.c].possVal
+		for _, b := range open[ia+1:] {
+			if !seesSquare(a.r, a.c, b.r, b.c) {
+				continue
+			}
+			bv := board[b.r][b.c].possVal
+			var relevant []*almostLockedSet
+			for _, s := range sets {
+				if s.values.Has(av) && s.values.Has(bv) {
+					relevant = append(relevant, s)
+				}
+			}
+			// The values of each square that go with at least one value of the other.
+			var allowedA, allowedB squareVal
+			av.each(func(x squareVal) {
+				kx := bits.TrailingZeros16(uint16(x))
+				bv.each(func(y squareVal) {
+					if y == x {
+						return
+					}
+					ky := bits.TrailingZeros16(uint16(y))
+					excluded := false
+					for _, s := range relevant {
+						if s.values.Has(x) && s.values.Has(y) && s.sees[kx][a.r][a.c] && s.sees[ky][b.r][b.c] {
+							excluded = true
+							break
+						}
+					}
+					if !excluded {
+						allowedA = allowedA.Add(x)
+						allowedB = allowedB.Add(y)
+					}
+				})
+			})
+			clearIfPossible(av.Remove(allowedA), a.r, a.c, alignedPairExclusion)
+			clearIfPossible(bv.Remove(allowedB), b.r, b.c, alignedPairExclusion)
+		}
+	}
+}
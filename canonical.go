@@ -0,0 +1,180 @@
+// canonical.go
+//
+// The minlex canonical form of a puzzle, for finding the same puzzle in disguise in a collection.  Swapping two rows of the same band,
+// two bands, two columns of the same stack or two stacks, transposing the grid, and relabelling the values all turn a puzzle into one
+// that is solved in exactly the same way.  Of all the grids a puzzle can be turned into like that, the canonical form is the one that
+// reads smallest as 81 squares in row order, with an unknown square smaller than any value, so two puzzles are the same in disguise if
+// and only if they have the same canonical form.
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// linePerms holds the 1296 orders of the nine rows, or columns, of a grid that keep each band, or stack, together: six orders of the
+// bands, and six orders of the rows within each.
+var linePerms = func() (perms [][9]int) {
+	orders := [6][3]int{{0, 1, 2}, {0, 2, 1}, {1, 0, 2}, {1, 2, 0}, {2, 0, 1}, {2, 1, 0}}
+	for _, bands := range orders {
+		for _, a := range orders {
+			for _, b := range orders {
+				for _, c := range orders {
+					var p [9]int
+					for k, within := range [3][3]int{a, b, c} {
+						for l := 0; l < 3; l++ {
+							p[3*k+l] = 3*bands[k] + within[l]
+						}
+					}
+					perms = append(perms, p)
+				}
+			}
+		}
+	}
+	return
+}()
+
+// Canonicalize returns the minlex canonical form of the puzzle g, with 0 for an unknown square, as its 81 squares in row order, with .
+// for an unknown square and the values written as the digits 1 to 9 whatever -symbols says.  Relabelling puts the values in the order
+// they first appear, so the first given is always a 1.  The form only allows for the usual rules: two Jigsaw, X or Killer puzzles with
+// the same form may still differ in their regions, diagonals or cages.
+func Canonicalize(g [9][9]int) string {
+	form, _ := canonicalForm(g)
+	var sb strings.Builder
+	for _, v := range form {
+		if v == 0 {
+			sb.WriteByte('.')
+		} else {
+			sb.WriteByte(byte('0' + v))
+		}
+	}
+	return sb.String()
+}
+
+// canonTransform is a rearrangement of a grid: transposed first if transpose is set, and then with row k taken from row rows[k] and
+// column k from column cols[k], and each value v relabelled as label[v].
+type canonTransform struct {
+	transpose  bool
+	rows, cols [9]int
+	label      [10]int
+}
+
+// canonicalForm returns the squares of the minlex canonical form of g in row order, and the rearrangement of g that makes it.  It tries
+// every order of the rows and of the columns, of g and of g transposed, giving up on each as soon as it reads larger than the smallest
+// found so far, which is usually within the first row.
+func canonicalForm(g [9][9]int) (best [81]int, tr canonTransform) {
+	for k := range best {
+		best[k] = 10
+	}
+	var t [9][9]int
+	for i := 0; i < 9; i++ {
+		for j := 0; j < 9; j++ {
+			t[i][j] = g[j][i]
+		}
+	}
+	var form [81]int
+	for s, src := range [2][9][9]int{g, t} {
+		for _, rows := range linePerms {
+			for _, cols := range linePerms {
+				var label [10]int
+				next, less, k := 1, false, 0
+				for ; k < 81; k++ {
+					v := src[rows[k/9]][cols[k%9]]
+					if v != 0 {
+						if label[v] == 0 {
+							label[v] = next
+							next++
+						}
+						v = label[v]
+					}
+					if !less {
+						if v > best[k] {
+							break
+						}
+						less = v < best[k]
+					}
+					form[k] = v
+				}
+				if less && k == 81 {
+					best = form
+					tr = canonTransform{s == 1, rows, cols, label}
+				}
+			}
+		}
+	}
+	return
+}
+
+// apply returns g rearranged by tr.
+func (tr canonTransform) apply(g [9][9]int) (out [9][9]int) {
+	for i := 0; i < 9; i++ {
+		for j := 0; j < 9; j++ {
+			r, c := tr.rows[i], tr.cols[j]
+			if tr.transpose {
+				r, c = c, r
+			}
+			out[i][j] = tr.label[g[r][c]]
+		}
+	}
+	return
+}
+
+// isomorphism reports whether b is a in disguise, rearranged in the ways the canonical form allows for, and if so returns a rearrangement
+// that takes a to b.  Both have the same canonical form, and the rearrangement of a into it followed by the reverse of that of b takes
+// a to b.
+func isomorphism(a, b [9][9]int) (tr canonTransform, ok bool) {
+	formA, ta := canonicalForm(a)
+	formB, tb := canonicalForm(b)
+	if formA != formB {
+		return tr, false
+	}
+	// Row k of the canonical form is row ta.rows[k] of a (transposed, if ta.transpose), and row tb.rows[k] of b.
+	var rowsA, colsA [9]int
+	for k := 0; k < 9; k++ {
+		rowsA[tb.rows[k]] = ta.rows[k]
+		colsA[tb.cols[k]] = ta.cols[k]
+	}
+	tr.transpose = ta.transpose != tb.transpose
+	tr.rows, tr.cols = rowsA, colsA
+	if tb.transpose {
+		// b transposed is a's rearrangement, so b itself takes its rows from a's columns and its columns from a's rows.
+		tr.rows, tr.cols = colsA, rowsA
+	}
+	var unlabel [10]int
+	for v := 1; v <= 9; v++ {
+		unlabel[tb.label[v]] = v
+	}
+	for v := 1; v <= 9; v++ {
+		if ta.label[v] != 0 {
+			tr.label[v] = unlabel[ta.label[v]]
+		}
+	}
+	if tr.apply(a) != b {
+		panic("the rearrangement found between two puzzles with the same canonical form does not take one to the other")
+	}
+	return tr, true
+}
+
+// String describes tr as a rearrangement of a puzzle, such as "transposed, with the rows in the order 3 1 2 4 5 6 7 8 9, the columns in
+// the order 1 2 3 4 5 6 7 8 9, and the values 1 2 4 relabelled 2 1 4".  Values that map to nothing are left out.
+func (tr canonTransform) String() string {
+	order := func(p [9]int) string {
+		parts := make([]string, 9)
+		for k, l := range p {
+			parts[k] = fmt.Sprint(l + 1)
+		}
+		return strings.Join(parts, " ")
+	}
+	var from, to []string
+	for v := 1; v <= 9; v++ {
+		if tr.label[v] != 0 {
+			from, to = append(from, string(squareSymbol(v, '.'))), append(to, string(squareSymbol(tr.label[v], '.')))
+		}
+	}
+	s := fmt.Sprintf("with the rows in the order %s, the columns in the order %s, and the values %s relabelled %s", order(tr.rows),
+		order(tr.cols), strings.Join(from, " "), strings.Join(to, " "))
+	if tr.transpose {
+		s = "transposed, " + s
+	}
+	return s
+}
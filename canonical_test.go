@@ -0,0 +1,56 @@
+package main
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// randomTransform returns a rearrangement of a grid chosen at random from those the canonical form allows for.
+func randomTransform(rng *rand.Rand) (tr canonTransform) {
+	tr.transpose = rng.Intn(2) == 1
+	tr.rows, tr.cols = linePerms[rng.Intn(len(linePerms))], linePerms[rng.Intn(len(linePerms))]
+	for k, v := range rng.Perm(9) {
+		tr.label[k+1] = v + 1
+	}
+	return
+}
+
+func TestCanonicalizeDisguises(t *testing.T) {
+	puzzle, _, err := readBoard("XWing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	form := Canonicalize(puzzle)
+	rng := rand.New(rand.NewSource(1))
+	for k := 0; k < 10; k++ {
+		tr := randomTransform(rng)
+		disguised := tr.apply(puzzle)
+		if got := Canonicalize(disguised); got != form {
+			t.Errorf("the puzzle %s has the canonical form %s, not %s", tr, got, form)
+		}
+		// isomorphism panics if the rearrangement it finds does not take one puzzle to the other.
+		found, ok := isomorphism(puzzle, disguised)
+		if !ok {
+			t.Errorf("the puzzle %s is not found to be the same puzzle", tr)
+		} else if found.apply(puzzle) != disguised {
+			t.Errorf("the rearrangement found, %s, does not take the puzzle to the puzzle %s", found, tr)
+		}
+	}
+}
+
+func TestCanonicalizeDifferentPuzzles(t *testing.T) {
+	a, _, err := readBoard("XWing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _, err := readBoard("Jellyfish")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if Canonicalize(a) == Canonicalize(b) {
+		t.Errorf("XWing and Jellyfish have the same canonical form")
+	}
+	if _, ok := isomorphism(a, b); ok {
+		t.Errorf("XWing and Jellyfish are found to be the same puzzle")
+	}
+}
@@ -0,0 +1,1056 @@
+// commands.go
+//
+// The command line is organised as a set of subcommands, each with its own flags:
+//
+//	sudoku solve [flags] <file>      solve a puzzle, printing the board at the end of each round
+//	sudoku generate [flags]          generate a new puzzle, with a unique solution
+//	sudoku rate [flags] <file>       rate the difficulty of a puzzle
+//	sudoku check [flags] <file>      check that a completed grid is a legal solution
+//	sudoku hint [flags] <file>       show the single next move the solver would make
+//	sudoku convert [flags] <in> <out> rewrite a puzzle in the layout given by the extension of <out>
+//	sudoku batch [flags] <file>      solve each of the puzzles in a file, one to a line, reporting one line for each
+//	sudoku canonical [flags] <file>  print the minlex canonical form of each of the puzzles in a file
+//	sudoku iso [flags] <a> <b>       tell whether two puzzles are the same in disguise
+//	sudoku deadly [flags] <file>     list the deadly patterns of a puzzle's solution, and the givens in each
+//
+// For compatibility, "sudoku <file>" is the same as "sudoku solve <file>".
+//
+// The exit status tells a script how things went: 0 when the puzzle was solved (or for check, the grid is a legal solution), 1 for a
+// usage or input error, 2 when the puzzle has no solution (or the grid is not a legal solution), and 3 when the implemented techniques
+// stalled before solving it.  solve -selfcheck exits with 4 when a deduction contradicts the solution, and solve -require-unique with 5
+// when the puzzle has more than one solution.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"runtime/pprof"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	exitOK         = 0
+	exitUsage      = 1
+	exitNoSolution = 2
+	exitStalled    = 3
+	exitSelfCheck  = 4
+	exitNotUnique  = 5
+)
+
+type command struct {
+	summary string
+	run     func(args []string) int
+}
+
+var commands map[string]command
+
+func init() {
+	// Assigned here rather than in the declaration, because the help command refers back to the table.
+	commands = map[string]command{
+		"solve":     {"solve a puzzle, printing the board at the end of each round", solveCmd},
+		"generate":  {"generate a new puzzle", generateCmd},
+		"rate":      {"rate the difficulty of a puzzle", rateCmd},
+		"check":     {"check that a completed grid is a legal solution", checkCmd},
+		"hint":      {"show the single next move the solver would make", hintCmd},
+		"batch":     {"solve each of the puzzles in a file, reporting one line for each", batchCmd},
+		"convert":   {"rewrite a puzzle in the layout given by the extension of the output file", convertCmd},
+		"diff":      {"list the squares where the givens of two puzzles differ", diffCmd},
+		"canonical": {"print the minlex canonical form of each puzzle in a file, to find the same puzzle in disguise", canonicalCmd},
+		"iso":       {"tell whether two puzzles are the same in disguise, and how one is rearranged into the other", isoCmd},
+		"deadly":    {"list the deadly patterns of a puzzle's solution, where two values could be swapped, and the givens in each", deadlyCmd},
+		"help":      {"list the subcommands", helpCmd},
+	}
+}
+
+func runCommand(args []string) int {
+	if len(args) < 1 {
+		fmt.Fprintf(os.Stderr, "Insufficient args, missing subcommand or input filename.\n")
+		helpCmd(nil)
+		return exitUsage
+	}
+	if cmd, ok := commands[args[0]]; ok {
+		return cmd.run(args[1:])
+	}
+	return solveCmd(args)
+}
+
+func helpCmd(args []string) int {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	fmt.Fprintf(os.Stderr, "Usage: sudoku <subcommand> [flags] [args]\n")
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %-10s %s\n", name, commands[name].summary)
+	}
+	fmt.Fprintf(os.Stderr, "Run \"sudoku <subcommand> -h\" for the flags of each subcommand.\n")
+	return exitOK
+}
+
+// newFlagSet returns the flag set for a subcommand, with the flags shared by every subcommand that reads a puzzle already defined.
+func newFlagSet(name, argsUsage string) (fs *flag.FlagSet, symbolsFlag, blankFlag *string) {
+	fs = flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: sudoku %s [flags] %s\n", name, argsUsage)
+		fs.PrintDefaults()
+	}
+	symbolsFlag = fs.String("symbols", string(symbols), "the nine characters used for the values one through nine, in order")
+	blankFlag = fs.String("blank", string(blankSymbol), "the character used for a square with no initial value")
+	fs.BoolVar(&xVariant, "x", false, "the puzzle is an X-Sudoku, with each value once on each of the two long diagonals as well")
+	return
+}
+
+// addDisableFlag adds the -disable flag, a comma separated list of techniques to turn off, to a subcommand that runs the solver.
+func addDisableFlag(fs *flag.FlagSet) {
+	names := make([]string, len(optionalTechniques))
+	for k, t := range optionalTechniques {
+		names[k] = string(t)
+	}
+	usage := "a comma separated `list` of techniques to turn off, from " + strings.Join(names, ", ")
+	fs.Func("disable", usage, func(list string) error {
+		for _, name := range strings.Split(list, ",") {
+			name = strings.TrimSpace(name)
+			found := false
+			for _, t := range optionalTechniques {
+				if string(t) == name {
+					disabled[t] = true
+					found = true
+				}
+			}
+			if !found {
+				return fmt.Errorf("unknown technique %q", name)
+			}
+		}
+		return nil
+	})
+}
+
+// addTechniqueOrderFlag adds the -technique-order flag, a comma separated list of techniques to put first, to a subcommand that runs the
+// solver.
+func addTechniqueOrderFlag(fs *flag.FlagSet) {
+	usage := "a comma separated `list` of techniques to run and credit first, in that order, as the names for -disable"
+	fs.Func("technique-order", usage, func(list string) error {
+		techniqueOrder = nil
+		for _, name := range strings.Split(list, ",") {
+			name = strings.TrimSpace(name)
+			found := false
+			for _, t := range optionalTechniques {
+				if string(t) == name {
+					found = true
+				}
+			}
+			if !found {
+				return fmt.Errorf("unknown technique %q", name)
+			}
+			if techniqueRank(technique(name)) < len(techniqueOrder) {
+				return fmt.Errorf("technique %q is listed twice", name)
+			}
+			techniqueOrder = append(techniqueOrder, technique(name))
+		}
+		return nil
+	})
+}
+
+// addAdvancedFlag adds the -advanced flag, to use the expensive techniques as well, to a subcommand that runs the solver.
+func addAdvancedFlag(fs *flag.FlagSet) {
+	names := make([]string, len(advancedTechniques))
+	for k, t := range advancedTechniques {
+		names[k] = string(t)
+	}
+	fs.BoolVar(&advanced, "advanced", false, "also use the techniques too slow to use by default: "+strings.Join(names, ", "))
+}
+
+// addBufferFlags adds the -buffer-size and -row-buffer-size flags, the capacities of the solver's channels, to a subcommand that runs the
+// solver.
+func addBufferFlags(fs *flag.FlagSet) {
+	for _, f := range []struct {
+		name, usage string
+		size        *int
+	}{
+		{"buffer-size", "the `number` of messages the buffer channel holds, which must be as many as a phase of a round sends", &bufferChanSize},
+		{"row-buffer-size", "the `number` of messages the channel of each row's square monitor holds", &inChanSize},
+	} {
+		size := f.size
+		fs.Func(f.name, fmt.Sprintf("%s (default %d)", f.usage, *size), func(s string) error {
+			n, err := strconv.Atoi(s)
+			if err != nil || n < 1 {
+				return fmt.Errorf("%q is not a positive number", s)
+			}
+			*size = n
+			return nil
+		})
+	}
+}
+
+// addBoundFlags adds the -max-chain-length and -max-als-size flags, which bound the work the chain and almost locked set techniques do,
+// to a subcommand that runs the solver.
+func addBoundFlags(fs *flag.FlagSet) {
+	for _, f := range []struct {
+		name, usage string
+		min         int
+		bound       *int
+	}{
+		{"max-chain-length", "the most `links` an alternating inference chain is followed for, or 0 for no limit", 0, &maxChainLength},
+		{"max-als-size", "the most `squares` an almost locked set is looked for with, for aligned pair exclusion and Sue de Coq", 1,
+			&maxALSSize},
+	} {
+		f := f
+		fs.Func(f.name, fmt.Sprintf("%s (default %d)", f.usage, *f.bound), func(s string) error {
+			n, err := strconv.Atoi(s)
+			if err != nil || n < f.min {
+				return fmt.Errorf("%q is not a number of at least %d", s, f.min)
+			}
+			*f.bound = n
+			return nil
+		})
+	}
+}
+
+// addBranchFlag adds the -branch flag, choosing the square the backtracking search branches on, to a subcommand that uses the search.
+func addBranchFlag(fs *flag.FlagSet) {
+	names := make([]string, len(branchRules))
+	for k, b := range branchRules {
+		names[k] = string(b)
+	}
+	usage := "the `rule` for the square the backtracking search tries values in next: " + strings.Join(names, ", ") + " (default mrv)"
+	fs.Func("branch", usage, func(name string) error {
+		for _, b := range branchRules {
+			if string(b) == name {
+				branch = b
+				return nil
+			}
+		}
+		return fmt.Errorf("unknown rule %q", name)
+	})
+}
+
+// addGridFlag adds the -grid flag, for giving the puzzle on the command line instead of in a file, to a subcommand that reads one puzzle.
+// Such a subcommand also takes the puzzle as nine arguments, one for each row, in place of the file name.
+func addGridFlag(fs *flag.FlagSet) {
+	fs.String("grid", "", "the puzzle as the 81 squares in row order, with the blank symbol, . or 0 for an unknown square, instead of a file")
+}
+
+// parseFileArgs parses the flags of a subcommand that takes one puzzle file, and reads that puzzle, or parses it from -grid if the
+// subcommand has that flag and it was given.
+func parseFileArgs(fs *flag.FlagSet, symbolsFlag, blankFlag *string, args []string) (grid [9][9]int, info puzzleInfo, err error) {
+	if err = fs.Parse(args); err != nil {
+		return
+	}
+	if err = setSymbols(*symbolsFlag, *blankFlag); err != nil {
+		return
+	}
+	if resumeFlag := fs.Lookup("resume"); resumeFlag != nil && resumeFlag.Value.String() != "" {
+		name := resumeFlag.Value.String()
+		f, err := os.Open(name)
+		if err != nil {
+			return grid, info, fmt.Errorf("Unable to open file %s: %v", name, err)
+		}
+		defer f.Close()
+		if err = LoadState(f); err != nil {
+			return grid, info, fmt.Errorf("Error reading file %s: %w", name, err)
+		}
+		return stateGrid(*resumeState), info, nil
+	}
+	if gridFlag := fs.Lookup("grid"); gridFlag != nil && gridFlag.Value.String() != "" {
+		if grid, err = parseGridString(gridFlag.Value.String()); err != nil {
+			err = fmt.Errorf("Error reading -grid: %w", err)
+		}
+		return
+	} else if gridFlag != nil && fs.NArg() == 9 {
+		// The puzzle can also be given as nine arguments, one for each row, in place of a file.
+		if grid, err = parseGridRows(fs.Args()); err != nil {
+			err = fmt.Errorf("Error reading the rows: %w", err)
+		}
+		return
+	}
+	if fs.NArg() < 1 {
+		return grid, info, fmt.Errorf("Insufficient args, missing input filename")
+	}
+	grid, info, err = readBoard(fs.Arg(0))
+	cages = info.Cages
+	setBlocks(info.Regions)
+	return
+}
+
+// checkClues warns when a puzzle has too few givens to have a unique solution, and reports whether to go ahead with it anyway.  The cages
+// of a Killer Sudoku constrain it enough without any givens at all, and minClues only holds for the usual blocks, not a Jigsaw's.
+func checkClues(grid [9][9]int, allowNonunique bool) bool {
+	n := countGivens(grid)
+	if n >= minClues || len(cages) > 0 || jigsaw {
+		return true
+	}
+	fmt.Fprintf(os.Stderr, "Warning: the puzzle has %d givens; with fewer than %d it almost certainly has more than one solution\n", n, minClues)
+	if !allowNonunique {
+		fmt.Fprintf(os.Stderr, "Error: refusing to continue, use -allow-nonunique to override\n")
+		return false
+	}
+	return true
+}
+
+// puzzleHeader returns the line solve prints above the first board, with the name, difficulty and source of the puzzle and its number
+// of givens, or "" for a puzzle that came without any of those.
+func puzzleHeader(info puzzleInfo, givens int) string {
+	if info.Name == "" && info.Difficulty == "" && info.Source == "" {
+		return ""
+	}
+	var parts []string
+	switch {
+	case info.Name != "" && info.Difficulty != "":
+		parts = append(parts, fmt.Sprintf("%s (%s)", info.Name, info.Difficulty))
+	case info.Name != "" || info.Difficulty != "":
+		parts = append(parts, info.Name+info.Difficulty)
+	}
+	if info.Source != "" {
+		parts = append(parts, "from "+info.Source)
+	}
+	parts = append(parts, fmt.Sprintf("%d givens", givens))
+	return strings.Join(parts, ", ")
+}
+
+func solveCmd(args []string) int {
+	fs, symbolsFlag, blankFlag := newFlagSet("solve", "<file>")
+	addGridFlag(fs)
+	allowFlag := fs.Bool("allow-nonunique", false, fmt.Sprintf("solve a puzzle even if it has fewer than %d givens", minClues))
+	cpuprofileFlag := fs.String("cpuprofile", "", "write a CPU profile of the solve to this file")
+	atRoundFlag := fs.Int("at-round", 0, "print only the board at the end of this round, and stop there")
+	answersFlag := fs.Bool("answers-only", false, "print only the board at the end, with the givens left empty, as an answer key")
+	maxRoundsFlag := fs.Int("maxrounds", 200, "give up as stalled if the puzzle is not solved after this many rounds; 0 for no limit")
+	histogramFlag := fs.Bool("histogram", false, "print how many squares have each number of possible values left, each round")
+	orderFlag := fs.Bool("order", false, "print the round each square was finalized in, after the last board")
+	statsFlag := fs.Bool("stats", false,
+		"print how many possible values each technique cleared, and how many deductions the solve took, after the last board")
+	labelsFlag := fs.Bool("labels", false, "letter the columns A to I and number the rows 1 to 9 around each board")
+	traceStringsFlag := fs.Bool("trace-strings", false, "print each board as one line of the 81 squares in row order, with . for an open one")
+	sideBySideFlag := fs.Bool("side-by-side", false, "print only the puzzle and the board it reached, next to each other, as for an answer sheet")
+	repeatFlag := fs.Int("repeat", 0, "solve the puzzle this many times without printing the boards, and print how long the solves took")
+	stallFormatFlag := fs.String("stall-format", "text",
+		"text to print the board each round, or json to print only the final board, or the possible values of every square as JSON if the "+
+			"solve stalls")
+	dotFlag := fs.String("dot", "", "write the most recent chain elimination to this file as a Graphviz graph")
+	formatFlag := fs.String("format", "text",
+		"text to print the board each round, compact to print it as plain lines of digits, html to print the final board as a web page with "+
+			"pencil marks, latex to print it as a LaTeX tabular, or png to draw the final board to the -o file")
+	outFlag := fs.String("o", "", "the file to write the output to instead of printing it, or the walkthrough, with -explain-html")
+	explainFlag := fs.Bool("explain-html", false, "write every deduction of the solve, with the board after it, to the -o file as an HTML page")
+	saveStateFlag := fs.String("save-state", "", "write every square's possible values at the end to this file, for -resume")
+	eventsFlag := fs.String("events", "", "write every deduction of the solve to this file, as a line of JSON each")
+	fs.String("resume", "", "carry on from a state written by -save-state, instead of a puzzle file")
+	noHeaderFlag := fs.Bool("no-header", false, "leave out the line naming the puzzle above the first board")
+	infoFlag := fs.Bool("info", false, "print the number of givens and a rough difficulty guessed from them, and stop without solving")
+	selfcheckFlag := fs.Bool("selfcheck", false, "check every deduction against the solution found by a search, and report any that contradict it")
+	requireUniqueFlag := fs.Bool("require-unique", false, "fail unless a search finds exactly one solution, before solving, for a publishing check")
+	addBranchFlag(fs)
+	addDisableFlag(fs)
+	addBufferFlags(fs)
+	addTechniqueOrderFlag(fs)
+	addAdvancedFlag(fs)
+	addBoundFlags(fs)
+	grid, info, err := parseFileArgs(fs, symbolsFlag, blankFlag, args)
+	if err == flag.ErrHelp {
+		return exitOK
+	} else if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitUsage
+	}
+	if *infoFlag {
+		fewest, most := givensPerUnit(grid)
+		fmt.Printf("Givens: %d, from %d to %d in each row, column and block\n", countGivens(grid), fewest, most)
+		fmt.Printf("Estimated difficulty: %s\n", estimateDifficulty(grid))
+		return exitOK
+	}
+	if !checkClues(grid, *allowFlag) {
+		return exitUsage
+	}
+	if *cpuprofileFlag != "" {
+		f, err := os.Create(*cpuprofileFlag)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: unable to create CPU profile: %v\n", err)
+			return exitUsage
+		}
+		defer f.Close()
+		if err := pprof.StartCPUProfile(f); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: unable to start CPU profile: %v\n", err)
+			return exitUsage
+		}
+		defer pprof.StopCPUProfile()
+	}
+	if *atRoundFlag < 0 {
+		fmt.Fprintf(os.Stderr, "Error: -at-round must not be negative\n")
+		return exitUsage
+	}
+	if *maxRoundsFlag < 0 {
+		fmt.Fprintf(os.Stderr, "Error: -maxrounds must not be negative\n")
+		return exitUsage
+	}
+	if *repeatFlag < 0 {
+		fmt.Fprintf(os.Stderr, "Error: -repeat must not be negative\n")
+		return exitUsage
+	}
+	switch {
+	case *formatFlag != "text" && *formatFlag != "compact" && *formatFlag != "html" && *formatFlag != "latex" && *formatFlag != "png":
+		fmt.Fprintf(os.Stderr, "Error: -format must be text, compact, html, latex or png, not %s\n", *formatFlag)
+		return exitUsage
+	case *orderFlag && (*formatFlag == "html" || *formatFlag == "latex"):
+		fmt.Fprintf(os.Stderr, "Error: -order prints plain text, so cannot go with -format %s\n", *formatFlag)
+		return exitUsage
+	case *statsFlag && (*formatFlag == "html" || *formatFlag == "latex"):
+		fmt.Fprintf(os.Stderr, "Error: -stats prints plain text, so cannot go with -format %s\n", *formatFlag)
+		return exitUsage
+	case *stallFormatFlag != "text" && *stallFormatFlag != "json":
+		fmt.Fprintf(os.Stderr, "Error: -stall-format must be text or json, not %s\n", *stallFormatFlag)
+		return exitUsage
+	case *stallFormatFlag == "json" && (*formatFlag != "text" && *formatFlag != "compact" || *atRoundFlag > 0 || *answersFlag):
+		fmt.Fprintf(os.Stderr, "Error: -stall-format json prints the final board itself, so cannot go with -format %s, -at-round or "+
+			"-answers-only\n", *formatFlag)
+		return exitUsage
+	case *repeatFlag > 0 && (*atRoundFlag > 0 || resumeState != nil):
+		fmt.Fprintf(os.Stderr, "Error: -repeat solves the whole puzzle from the start each time, so cannot go with -at-round or -resume\n")
+		return exitUsage
+	case *traceStringsFlag && (*formatFlag != "text" || *labelsFlag):
+		fmt.Fprintf(os.Stderr, "Error: -trace-strings prints each board as a line of its own, so cannot go with -format %s or -labels\n",
+			*formatFlag)
+		return exitUsage
+	case *sideBySideFlag && (*formatFlag != "text" && *formatFlag != "compact" || *atRoundFlag > 0 || *stallFormatFlag == "json"):
+		fmt.Fprintf(os.Stderr, "Error: -side-by-side prints the two boards itself, so cannot go with -format %s, -at-round or "+
+			"-stall-format json\n", *formatFlag)
+		return exitUsage
+	case *labelsFlag && *formatFlag != "text":
+		fmt.Fprintf(os.Stderr, "Error: -labels only goes with the board drawn with box characters, not -format %s\n", *formatFlag)
+		return exitUsage
+	case *formatFlag == "latex" && jigsaw:
+		fmt.Fprintf(os.Stderr, "Error: -format latex cannot draw the regions of a Jigsaw\n")
+		return exitUsage
+	case *formatFlag == "png" && *outFlag == "":
+		fmt.Fprintf(os.Stderr, "Error: -format png needs -o to name the image file\n")
+		return exitUsage
+	case *explainFlag && *outFlag == "":
+		fmt.Fprintf(os.Stderr, "Error: -explain-html needs -o to name the HTML file\n")
+		return exitUsage
+	case *explainFlag && *formatFlag == "png":
+		fmt.Fprintf(os.Stderr, "Error: -explain-html and -format png cannot both write to the -o file\n")
+		return exitUsage
+	}
+	// Counting the solutions is a search, so it is done at most once, for both -require-unique and -selfcheck.
+	var sols [][9][9]int
+	if *requireUniqueFlag || *selfcheckFlag {
+		sols = AllSolutions(grid, 2)
+	}
+	if *requireUniqueFlag {
+		switch len(sols) {
+		case 0:
+			fmt.Printf("The puzzle has no solution, as a search finds none\n")
+			return exitNoSolution
+		case 2:
+			fmt.Printf("The puzzle has more than one solution, as a search finds two\n")
+			return exitNotUnique
+		}
+	}
+	// The boards go to the -o file in place of stdout, unless it is taken by the image or the walkthrough.  They are gathered up and
+	// written once the solve is over.
+	var out io.Writer = os.Stdout
+	var outBuf bytes.Buffer
+	toFile := *outFlag != "" && *formatFlag != "png" && !*explainFlag
+	if toFile {
+		out = &outBuf
+	}
+	// An HTML page or LaTeX tabular is a document of its own, drawn once the solve is over, with the header and outcome inside it.
+	document := *formatFlag == "html" || *formatFlag == "latex"
+	// With -stall-format json only the final board is printed, or in its place the JSON for a downstream program to pick up.
+	stallJSON := *stallFormatFlag == "json"
+	if header := puzzleHeader(info, countGivens(grid)); header != "" && !*noHeaderFlag && !document && !stallJSON && !*traceStringsFlag {
+		fmt.Fprintln(out, header)
+	}
+	o := solveOptions{showRounds: *atRoundFlag == 0 && *formatFlag != "png" && !document && !*answersFlag && !stallJSON && !*sideBySideFlag,
+		compact: *formatFlag == "compact",
+		atRound: *atRoundFlag, maxRounds: *maxRoundsFlag, answersOnly: *answersFlag && !document && !*sideBySideFlag, histogram: *histogramFlag, labels: *labelsFlag, traceStrings: *traceStringsFlag, out: out}
+	if *selfcheckFlag {
+		if len(sols) != 1 {
+			fmt.Fprintf(os.Stderr, "Error: -selfcheck needs a puzzle with exactly one solution to check against\n")
+			return exitUsage
+		}
+		o.solution = &sols[0]
+	}
+	if *repeatFlag > 0 {
+		// Only the timings are printed, along with whatever the last solve leaves for the other flags.
+		o.showRounds, o.answersOnly, o.histogram = false, false, false
+		times := make([]time.Duration, *repeatFlag)
+		for k := range times {
+			start := time.Now()
+			solve(grid, o)
+			times[k] = time.Since(start)
+		}
+		writeTimings(out, times)
+	} else {
+		solve(grid, o)
+	}
+	if *sideBySideFlag {
+		// A puzzle whose givens contradict each other never had its board set up, so show the givens alone on the right as well.
+		state := boardState()
+		if givensConflict(grid) != "" {
+			state = gridState(grid)
+		}
+		if *answersFlag {
+			state = answersState(state)
+		}
+		writeSideBySide(out, gridState(grid), state, boardWriter())
+	}
+	if stallJSON {
+		if stalled && !noSolution.Load() {
+			if err := writeCandidatesJSON(out, boardState()); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				return exitUsage
+			}
+		} else {
+			showBoard()
+		}
+	}
+	if *orderFlag {
+		writeOrder(out, finalizedRounds(), grid)
+	}
+	if *statsFlag {
+		writeStats(out, eliminationCounts())
+		fmt.Fprintf(out, "Solution path: %d deductions\n", pathLength())
+	}
+	if *saveStateFlag != "" {
+		if err := saveStateFile(*saveStateFlag); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return exitUsage
+		}
+	}
+	if *eventsFlag != "" {
+		if err := writeEventsFile(*eventsFlag); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return exitUsage
+		}
+	}
+	if *formatFlag == "png" {
+		if err := writePNG(*outFlag, grid); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return exitUsage
+		}
+	}
+	if *dotFlag != "" {
+		if err := writeDot(*dotFlag); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		}
+	}
+	outcome, code := "The puzzle is solved", exitOK
+	switch {
+	case *selfcheckFlag && selfCheckFailed():
+		outcome, code = "The self-check found deductions that contradict the solution", exitSelfCheck
+	case noSolution.Load():
+		outcome, code = fmt.Sprintf("The puzzle has no solution: %v", noSolutionError()), exitNoSolution
+	case outOfRounds:
+		outcome, code = fmt.Sprintf("The puzzle was not solved within %d rounds", *maxRoundsFlag), exitStalled
+	case stalled:
+		outcome, code = "The puzzle cannot be solved any further with the implemented techniques", exitStalled
+	}
+	if document {
+		title := puzzleHeader(info, countGivens(grid))
+		// A puzzle whose givens contradict each other never had its board set up, so show the givens alone.
+		state, givens := boardState(), grid
+		if givensConflict(grid) != "" {
+			state = gridState(grid)
+		}
+		if *answersFlag {
+			state, givens = answersState(state), [9][9]int{}
+		}
+		if *formatFlag == "latex" {
+			if *noHeaderFlag {
+				title = ""
+			}
+			writeBoardLaTeX(out, givens, state, title, outcome)
+		} else {
+			if title == "" {
+				title = "Sudoku"
+			}
+			writeBoardHTML(out, givens, state, title, outcome)
+		}
+	}
+	if *explainFlag {
+		title := puzzleHeader(info, countGivens(grid))
+		if title == "" {
+			title = "Sudoku walkthrough"
+		}
+		if err := writeExplainHTML(*outFlag, grid, title, outcome); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return exitUsage
+		}
+	}
+	if code != exitOK && !document && !(stallJSON && code == exitStalled) {
+		fmt.Fprintln(out, outcome)
+	}
+	if toFile {
+		if err := os.WriteFile(*outFlag, outBuf.Bytes(), 0666); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return exitUsage
+		}
+	}
+	return code
+}
+
+func checkCmd(args []string) int {
+	fs, symbolsFlag, blankFlag := newFlagSet("check", "<file>")
+	addGridFlag(fs)
+	grid, _, err := parseFileArgs(fs, symbolsFlag, blankFlag, args)
+	if err == flag.ErrHelp {
+		return exitOK
+	} else if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitUsage
+	}
+	name := fs.Arg(0)
+	if name == "" || fs.NArg() == 9 {
+		name = "The grid"
+	}
+	if !IsValidSolution(grid) || (xVariant && !diagonalsValid(grid)) || !cagesValid(grid) {
+		fmt.Printf("%s is not a legal solution\n", name)
+		return exitNoSolution
+	}
+	fmt.Printf("%s is a legal solution\n", name)
+	return exitOK
+}
+
+func hintCmd(args []string) int {
+	fs, symbolsFlag, blankFlag := newFlagSet("hint", "<file>")
+	addGridFlag(fs)
+	allowFlag := fs.Bool("allow-nonunique", false, fmt.Sprintf("give a hint even if the puzzle has fewer than %d givens", minClues))
+	addDisableFlag(fs)
+	addBufferFlags(fs)
+	addTechniqueOrderFlag(fs)
+	addAdvancedFlag(fs)
+	addBoundFlags(fs)
+	grid, _, err := parseFileArgs(fs, symbolsFlag, blankFlag, args)
+	if err == flag.ErrHelp {
+		return exitOK
+	} else if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitUsage
+	}
+	if !checkClues(grid, *allowFlag) {
+		return exitUsage
+	}
+	r, c, value, technique, ok := NextHint(grid)
+	if !ok && noSolution.Load() {
+		fmt.Printf("The puzzle has no solution: %v\n", noSolutionError())
+		return exitNoSolution
+	} else if !ok {
+		fmt.Printf("No further squares can be deduced\n")
+		return exitStalled
+	}
+	fmt.Printf("Row %d, column %d is %c (%s)\n", r+1, c+1, symbols[value-1], technique)
+	return exitOK
+}
+
+// batchLogEntry is the line batch -log writes for each puzzle, counting the puzzles in the file from 1.
+type batchLogEntry struct {
+	Puzzle  int     `json:"puzzle"`
+	Givens  int     `json:"givens"`
+	Rounds  int     `json:"rounds"`
+	Outcome string  `json:"outcome"`
+	Seconds float64 `json:"seconds"`
+}
+
+// batchCmd solves the puzzles one after another, since there is only the one board and set of square monitors.  It reports each
+// puzzle in input order as the board it reached, in the same 81 square form, and the outcome: solved, stalled, no-solution, or invalid
+// for a line that could not be read.  The exit status is that of the worst outcome, with invalid the worst.
+func batchCmd(args []string) int {
+	fs, symbolsFlag, blankFlag := newFlagSet("batch", "<file>")
+	addDisableFlag(fs)
+	addBufferFlags(fs)
+	addTechniqueOrderFlag(fs)
+	addAdvancedFlag(fs)
+	addBoundFlags(fs)
+	logFlag := fs.String("log", "", "append a line of JSON for each puzzle, with its givens, rounds, outcome and time taken, to this file")
+	sampleFlag := fs.Int("sample", 0, "solve only this many of the puzzles, picked at random; 0 solves them all")
+	seedFlag := fs.Int64("seed", 0, "the seed for picking the -sample, to pick the same puzzles again; 0 picks one from the time")
+	failFastFlag := fs.Bool("fail-fast", false, "stop at the first puzzle that is invalid or has no solution, instead of going on to the rest")
+	if err := fs.Parse(args); err == flag.ErrHelp {
+		return exitOK
+	} else if err != nil {
+		return exitUsage
+	}
+	if *sampleFlag < 0 {
+		fmt.Fprintf(os.Stderr, "Error: -sample must not be negative\n")
+		return exitUsage
+	}
+	if fs.NArg() < 1 {
+		fmt.Fprintf(os.Stderr, "Error: Insufficient args, missing input filename\n")
+		return exitUsage
+	}
+	if err := setSymbols(*symbolsFlag, *blankFlag); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitUsage
+	}
+	grids, errs, err := readBatch(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitUsage
+	}
+	var logFile *os.File
+	if *logFlag != "" {
+		if logFile, err = os.OpenFile(*logFlag, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: Unable to open log file %s: %v\n", *logFlag, err)
+			return exitUsage
+		}
+		defer logFile.Close()
+	}
+	// A sample is solved and reported in input order, with each puzzle keeping its number in the file for the log.
+	picked := make([]int, len(grids))
+	for k := range picked {
+		picked[k] = k
+	}
+	if *sampleFlag > 0 && *sampleFlag < len(grids) {
+		seed := *seedFlag
+		if seed == 0 {
+			seed = time.Now().UnixNano()
+		}
+		picked = rand.New(rand.NewSource(seed)).Perm(len(grids))[:*sampleFlag]
+		sort.Ints(picked)
+	}
+	counts := map[string]int{}
+	for _, k := range picked {
+		grid := grids[k]
+		outcome := "solved"
+		givens := countGivens(grid)
+		start := time.Now()
+		roundsRun = 0
+		if errs[k] != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", errs[k])
+			outcome = "invalid"
+		} else {
+			solve(grid, solveOptions{})
+			if noSolution.Load() {
+				// The board may not have been set up at all, so report the puzzle as it was given.
+				outcome = "no-solution"
+			} else {
+				grid = boardGrid()
+				if stalled {
+					outcome = "stalled"
+				}
+			}
+		}
+		counts[outcome]++
+		line := make([]rune, 0, 81)
+		for i := 0; i < 9; i++ {
+			for j := 0; j < 9; j++ {
+				line = append(line, squareSymbol(grid[i][j], blankSymbol))
+			}
+		}
+		fmt.Printf("%s %s\n", string(line), outcome)
+		if logFile != nil {
+			entry := batchLogEntry{k + 1, givens, roundsRun, outcome, time.Since(start).Seconds()}
+			if err := json.NewEncoder(logFile).Encode(entry); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: Error writing log file %s: %v\n", *logFlag, err)
+				return exitUsage
+			}
+		}
+		if *failFastFlag && (outcome == "invalid" || outcome == "no-solution") {
+			// A puzzle that cannot be read, or contradicts itself, suggests the file is corrupt, so the rest are not worth solving.
+			if outcome == "no-solution" {
+				fmt.Fprintf(os.Stderr, "Error: puzzle %d has no solution: %v\n", k+1, noSolutionError())
+			}
+			fmt.Fprintf(os.Stderr, "Error: stopping at puzzle %d of %d, for -fail-fast\n", k+1, len(grids))
+			if outcome == "invalid" {
+				return exitUsage
+			}
+			return exitNoSolution
+		}
+	}
+	switch {
+	case counts["invalid"] > 0:
+		return exitUsage
+	case counts["no-solution"] > 0:
+		return exitNoSolution
+	case counts["stalled"] > 0:
+		return exitStalled
+	}
+	return exitOK
+}
+
+func convertCmd(args []string) int {
+	fs, symbolsFlag, blankFlag := newFlagSet("convert", "<in> <out>")
+	grid, info, err := parseFileArgs(fs, symbolsFlag, blankFlag, args)
+	if err == flag.ErrHelp {
+		return exitOK
+	} else if err == nil && fs.NArg() < 2 {
+		err = fmt.Errorf("Insufficient args, missing output filename")
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitUsage
+	}
+	if err := writeBoard(fs.Arg(1), grid, info); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitUsage
+	}
+	return exitOK
+}
+
+func diffCmd(args []string) int {
+	fs, symbolsFlag, blankFlag := newFlagSet("diff", "<a> <b>")
+	a, _, err := parseFileArgs(fs, symbolsFlag, blankFlag, args)
+	if err == flag.ErrHelp {
+		return exitOK
+	} else if err == nil && fs.NArg() < 2 {
+		err = fmt.Errorf("Insufficient args, missing second filename")
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitUsage
+	}
+	b, _, err := readBoard(fs.Arg(1))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitUsage
+	}
+	n := 0
+	for i := 0; i < 9; i++ {
+		for j := 0; j < 9; j++ {
+			if a[i][j] != b[i][j] {
+				fmt.Printf("row %d column %d: %c in %s, %c in %s\n", i+1, j+1,
+					squareSymbol(a[i][j], '.'), fs.Arg(0), squareSymbol(b[i][j], '.'), fs.Arg(1))
+				n++
+			}
+		}
+	}
+	switch {
+	case n == 1:
+		fmt.Printf("1 square differs\n")
+	case n > 1:
+		fmt.Printf("%d squares differ\n", n)
+	}
+	if n > 0 {
+		return exitNoSolution
+	}
+	fmt.Printf("The givens are the same\n")
+	return exitOK
+}
+
+func generateCmd(args []string) int {
+	fs := flag.NewFlagSet("generate", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: sudoku generate [flags]\n")
+		fs.PrintDefaults()
+	}
+	minFlag := fs.Int("minclues", minClues, "the fewest givens the puzzle may have")
+	maxFlag := fs.Int("maxclues", 81, "the most givens the puzzle may have")
+	attemptsFlag := fs.Int("attempts", 100, "how many completed grids to try before giving up on the range of givens")
+	seedFlag := fs.Int64("seed", 0, "the seed for the random choices, to make the same puzzle again; 0 picks one from the time")
+	minPerUnitFlag := fs.Int("min-per-unit", 0, "the fewest givens to leave in each row, column and block, for a balanced puzzle")
+	addBranchFlag(fs)
+	if err := fs.Parse(args); err == flag.ErrHelp {
+		return exitOK
+	} else if err != nil {
+		return exitUsage
+	}
+	if *minPerUnitFlag < 0 || *minPerUnitFlag > 9 {
+		fmt.Fprintf(os.Stderr, "Error: -min-per-unit must be from 0 to 9\n")
+		return exitUsage
+	}
+	seed := *seedFlag
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	branchRand = rand.New(rand.NewSource(seed))
+	puzzle, err := GenerateBalanced(rand.New(rand.NewSource(seed)), *minFlag, *maxFlag, *attemptsFlag, *minPerUnitFlag)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitUsage
+	}
+	writeSemicolonBoard(os.Stdout, puzzle)
+	return exitOK
+}
+
+func canonicalCmd(args []string) int {
+	fs, symbolsFlag, blankFlag := newFlagSet("canonical", "<file>")
+	if err := fs.Parse(args); err == flag.ErrHelp {
+		return exitOK
+	} else if err != nil {
+		return exitUsage
+	}
+	if fs.NArg() < 1 {
+		fmt.Fprintf(os.Stderr, "Error: Insufficient args, missing input filename\n")
+		return exitUsage
+	}
+	if err := setSymbols(*symbolsFlag, *blankFlag); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitUsage
+	}
+	grids, errs, err := readBatch(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitUsage
+	}
+	code := exitOK
+	for k, grid := range grids {
+		if errs[k] != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", errs[k])
+			fmt.Println("invalid")
+			code = exitUsage
+			continue
+		}
+		fmt.Println(Canonicalize(grid))
+	}
+	return code
+}
+
+// writeTimings writes the times taken by the solves of solve -repeat to w as one line, with the first, which pays for warming up, as
+// well as the fastest, the mean and the slowest.
+func writeTimings(w io.Writer, times []time.Duration) {
+	var total time.Duration
+	fastest, slowest := times[0], times[0]
+	for _, t := range times {
+		total += t
+		if t < fastest {
+			fastest = t
+		}
+		if t > slowest {
+			slowest = t
+		}
+	}
+	mean := total / time.Duration(len(times))
+	fmt.Fprintf(w, "%d solves: first %v, min %v, avg %v, max %v\n", len(times), times[0].Round(time.Microsecond),
+		fastest.Round(time.Microsecond), mean.Round(time.Microsecond), slowest.Round(time.Microsecond))
+}
+
+func isoCmd(args []string) int {
+	fs, symbolsFlag, blankFlag := newFlagSet("iso", "<a> <b>")
+	a, _, err := parseFileArgs(fs, symbolsFlag, blankFlag, args)
+	if err == flag.ErrHelp {
+		return exitOK
+	} else if err == nil && fs.NArg() < 2 {
+		err = fmt.Errorf("Insufficient args, missing second filename")
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitUsage
+	}
+	b, _, err := readBoard(fs.Arg(1))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitUsage
+	}
+	tr, ok := isomorphism(a, b)
+	if !ok {
+		fmt.Printf("The puzzles are not the same in disguise\n")
+		return exitNoSolution
+	}
+	fmt.Printf("The puzzles are the same in disguise: %s is %s %s\n", fs.Arg(1), fs.Arg(0), tr)
+	return exitOK
+}
+
+func deadlyCmd(args []string) int {
+	fs, symbolsFlag, blankFlag := newFlagSet("deadly", "<file>")
+	addGridFlag(fs)
+	maxSize := fs.Int("max-size", 0, "only list the patterns of at most this many squares, or all of them if 0")
+	grid, _, err := parseFileArgs(fs, symbolsFlag, blankFlag, args)
+	if err == flag.ErrHelp {
+		return exitOK
+	} else if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitUsage
+	}
+	solutions := AllSolutions(grid, 2)
+	if len(solutions) == 0 {
+		fmt.Printf("The puzzle has no solution\n")
+		return exitNoSolution
+	}
+	if len(solutions) > 1 {
+		fmt.Printf("The puzzle has more than one solution; these are the patterns of the first found\n")
+	}
+	// A completed grid is taken as the solution itself, and every square of it is a given, so the givens are not worth listing.
+	complete := countGivens(grid) == 81
+	n, single, none := 0, 0, 0
+	for _, p := range DeadlyPatterns(solutions[0], grid) {
+		if *maxSize > 0 && len(p.Squares) > *maxSize {
+			break
+		}
+		n++
+		if complete {
+			fmt.Printf("%s\n", p)
+			continue
+		}
+		var givens []string
+		for _, s := range p.Squares {
+			if s.Given {
+				givens = append(givens, fmt.Sprintf("R%dC%d", s.R+1, s.C+1))
+			}
+		}
+		switch len(givens) {
+		case 0:
+			none++
+			fmt.Printf("%s: no givens, so swapping them gives another solution\n", p)
+		case 1:
+			single++
+			fmt.Printf("%s: only the given at %s, which cannot be removed\n", p, givens[0])
+		default:
+			fmt.Printf("%s: givens at %s\n", p, strings.Join(givens, " "))
+		}
+	}
+	if complete {
+		fmt.Printf("%d deadly patterns\n", n)
+	} else {
+		fmt.Printf("%d deadly patterns, %d with a single given, %d with none\n", n, single, none)
+	}
+	return exitOK
+}
+
+func rateCmd(args []string) int {
+	fs, symbolsFlag, blankFlag := newFlagSet("rate", "<file>")
+	addGridFlag(fs)
+	allowFlag := fs.Bool("allow-nonunique", false, fmt.Sprintf("rate a puzzle even if it has fewer than %d givens", minClues))
+	addDisableFlag(fs)
+	addBufferFlags(fs)
+	addAdvancedFlag(fs)
+	addBoundFlags(fs)
+	scoreFlag := fs.Bool("score", false, "print a difficulty score from 1 to 10 instead of the techniques needed")
+	grid, _, err := parseFileArgs(fs, symbolsFlag, blankFlag, args)
+	if err == flag.ErrHelp {
+		return exitOK
+	} else if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitUsage
+	}
+	if !checkClues(grid, *allowFlag) {
+		return exitUsage
+	}
+	if *scoreFlag {
+		score := Rate(grid)
+		if score == 0 {
+			fmt.Printf("The puzzle has no solution: %v\n", noSolutionError())
+			return exitNoSolution
+		}
+		fmt.Printf("Score: %d/10\n", score)
+		if score == 10 {
+			return exitStalled
+		}
+		return exitOK
+	}
+	names, exact, ok := MinimalTechniques(grid)
+	switch {
+	case !ok && noSolution.Load():
+		fmt.Printf("The puzzle has no solution: %v\n", noSolutionError())
+		return exitNoSolution
+	case !ok:
+		fmt.Printf("The puzzle cannot be solved with the implemented techniques\n")
+		return exitStalled
+	case len(names) == 0:
+		fmt.Printf("Techniques needed: none beyond naked singles\n")
+	default:
+		fmt.Printf("Techniques needed: %s\n", strings.Join(names, ", "))
+	}
+	if !exact {
+		fmt.Printf("(found by dropping one technique at a time, so a smaller set may exist)\n")
+	}
+	return exitOK
+}
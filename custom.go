@@ -0,0 +1,54 @@
+// custom.go
+//
+// Techniques from outside the solver.  A function registered with RegisterTechnique is called once a round, along with the techniques
+// that span the whole grid, with a copy of the possible values of every square, and the values it rules out are cleared just as the
+// built-in techniques' are.  Registering one from an init function in a file of its own is enough to try out a new technique without
+// touching the rest of the solver.
+package main
+
+import "fmt"
+
+// Elimination is a deduction from a custom technique, or one of those PendingEliminations returns: the values in Values, a bit vector like
+// the possible values of a square, cannot be in the square at Row and Col, counting from 0.  Technique names the technique that made it,
+// for PendingEliminations; a custom technique's are always reported under its own name, and it can leave Technique empty.
+type Elimination struct {
+	Row, Col  int
+	Values    squareVal
+	Technique string
+}
+
+// TechniqueFunc is a custom technique.  snapshot holds the possible values of each square at the end of the round's analysis phase, with
+// a finalized square holding just its value, and the function returns what it can rule out from them.  Row and Col must each be from 0
+// to 8, and Values a set of the values one through nine; an Elimination for a square off the grid is ignored, as are any other bits
+// of Values, so a faulty technique cannot stop the solve.  Ruling out every value left in a square is a contradiction, as it would be
+// for a built-in technique, and Solve reports it.
+type TechniqueFunc func(snapshot [9][9]squareVal) []Elimination
+
+type customTechnique struct {
+	name technique
+	f    TechniqueFunc
+}
+
+// RegisterTechnique adds f to the techniques the solver uses.  name is reported as the reason for its eliminations, and can be given
+// to -disable like the name of any other technique.  It must be called before the command line is parsed, from an init function.
+func RegisterTechnique(name string, f TechniqueFunc) {
+	for _, t := range optionalTechniques {
+		if string(t) == name {
+			panic(fmt.Sprintf("there is already a technique called %s", name))
+		}
+	}
+	ct := customTechnique{technique(name), f}
+	optionalTechniques = append(optionalTechniques, ct.name)
+	gridTechniques = append(gridTechniques, gridTechnique{ct.name, func() { checkCustomTechnique(ct) }})
+}
+
+// checkCustomTechnique clears the values ct rules out from the board as it stands, passing over any Elimination TechniqueFunc says is
+// ignored.
+func checkCustomTechnique(ct customTechnique) {
+	for _, e := range ct.f(boardState()) {
+		if e.Row < 0 || e.Row > 8 || e.Col < 0 || e.Col > 8 {
+			continue
+		}
+		clearIfPossible(e.Values&blank, e.Row, e.Col, ct.name)
+	}
+}
@@ -0,0 +1,38 @@
+package main
+
+import "testing"
+
+func TestCustomTechniqueOffGrid(t *testing.T) {
+	var state [9][9]squareVal
+	for i := 0; i < 9; i++ {
+		for j := 0; j < 9; j++ {
+			state[i][j] = blank
+		}
+	}
+	setBoard(state)
+	var msgs []updateMsg
+	pendingMsgs = &msgs
+	defer func() { pendingMsgs = nil }()
+	checkCustomTechnique(customTechnique{"test", func([9][9]squareVal) []Elimination {
+		return []Elimination{{-1, 0, one, ""}, {0, -1, one, ""}, {9, 0, one, ""}, {0, 9, one, ""}, {2, 3, one | 1<<12, ""}}
+	}})
+	if len(msgs) != 1 || msgs[0] != (updateMsg{one, clear, 2, 3, "test"}) {
+		t.Errorf("the eliminations off the grid, or the bits past nine, were not ignored: %+v", msgs)
+	}
+}
+
+func TestCustomTechniqueSolve(t *testing.T) {
+	savedOptional, savedGrid := optionalTechniques, gridTechniques
+	defer func() { optionalTechniques, gridTechniques = savedOptional, savedGrid }()
+	RegisterTechnique("off-grid", func([9][9]squareVal) []Elimination {
+		return []Elimination{{Row: 9, Col: 0, Values: blank}, {Row: 0, Col: -3, Values: blank}}
+	})
+	puzzle, _, err := readBoard("XWing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	solution, err := Solve(puzzle)
+	if err != nil || !IsValidSolution(solution) {
+		t.Errorf("a custom technique ruling out values off the grid stopped the solve: %v", err)
+	}
+}
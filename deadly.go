@@ -0,0 +1,170 @@
+// deadly.go
+//
+// Deadly patterns, for a puzzle author to see which givens keep a puzzle unique.  In a solution, the squares holding either of two
+// values a and b form closed loops, each going from the a of a row to the b of that row, up or down its column to the a there, and so on
+// back to the start.  Swapping a and b along some of the loops leaves every row and column with one of each, and if each block, and
+// each diagonal of an X-Sudoku or cage of a Killer, also ends up with as many of a as before, the swap is another solution to any puzzle
+// none of whose givens it touches.  The smallest such set is the deadly rectangle, two squares in each of two rows, in the same two
+// columns and two blocks.  Every puzzle with a unique solution has a given in each of these patterns, and a given that is the only one in
+// a pattern cannot be removed without losing uniqueness.  Only swaps of two values are found; a pattern that cycles three or more values
+// around is left out.
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// PatternSquare is a square of a deadly pattern, counting from 0, and whether it is a given of the puzzle.
+type PatternSquare struct {
+	R, C  int
+	Given bool
+}
+
+// DeadlyPattern is a set of squares of a solution holding two values, Values, in which the two can be swapped to give another solution.
+type DeadlyPattern struct {
+	Values  [2]int
+	Squares []PatternSquare
+}
+
+// String describes p, such as "1 and 2 at R1C1 R1C5 R2C1 R2C5", with the symbols set by -symbols.
+func (p DeadlyPattern) String() string {
+	names := make([]string, len(p.Squares))
+	for k, s := range p.Squares {
+		names[k] = fmt.Sprintf("R%dC%d", s.R+1, s.C+1)
+	}
+	return fmt.Sprintf("%c and %c at %s", squareSymbol(p.Values[0], '.'), squareSymbol(p.Values[1], '.'), strings.Join(names, " "))
+}
+
+// DeadlyPatterns returns the smallest deadly patterns of the completed grid solution, those that hold no smaller one, marking the squares
+// that are givens of the puzzle g.  They are ordered from the fewest squares to the most, and then by their values.
+func DeadlyPatterns(solution, g [9][9]int) (patterns []DeadlyPattern) {
+	// units are the groups of squares, other than rows and columns, that must hold each value at most once.
+	var units [][]gridPos
+	for b := range blockSquares {
+		units = append(units, blockSquares[b][:])
+	}
+	if xVariant {
+		var diags [2][]gridPos
+		for k := 0; k < 9; k++ {
+			diags[0] = append(diags[0], gridPos{k, k})
+			diags[1] = append(diags[1], gridPos{k, 8 - k})
+		}
+		units = append(units, diags[:]...)
+	}
+	for _, cg := range cages {
+		units = append(units, cg.squares)
+	}
+
+	for a := 1; a <= 9; a++ {
+		for b := a + 1; b <= 9; b++ {
+			loops := valueLoops(solution, a, b)
+			// A swap along a set of loops works if every unit has as many squares holding a as holding b in the set.
+			works := func(set int) bool {
+				for _, u := range units {
+					diff := 0
+					for _, p := range u {
+						if set&(1<<loops[p.r][p.c]) == 0 {
+							continue
+						}
+						switch solution[p.r][p.c] {
+						case a:
+							diff++
+						case b:
+							diff--
+						}
+					}
+					if diff != 0 {
+						return false
+					}
+				}
+				return true
+			}
+			n := 0
+			for i := 0; i < 9; i++ {
+				for j := 0; j < 9; j++ {
+					if loops[i][j] > n {
+						n = loops[i][j]
+					}
+				}
+			}
+			// Loop k is bit k of a set, counting from 1, and bit 0 stands for the squares holding neither value.  The sets are tried
+			// in order of size, so one holding a smaller working set is always tried after it.
+			var found []int
+			sets := make([]int, 0, 1<<n)
+			for set := 1; set < 1<<n; set++ {
+				sets = append(sets, set<<1)
+			}
+			sort.SliceStable(sets, func(x, y int) bool { return loopSquares(loops, sets[x]) < loopSquares(loops, sets[y]) })
+		sets:
+			for _, set := range sets {
+				for _, f := range found {
+					if set&f == f {
+						continue sets
+					}
+				}
+				if !works(set) {
+					continue
+				}
+				found = append(found, set)
+				p := DeadlyPattern{Values: [2]int{a, b}}
+				for i := 0; i < 9; i++ {
+					for j := 0; j < 9; j++ {
+						if set&(1<<loops[i][j]) != 0 {
+							p.Squares = append(p.Squares, PatternSquare{i, j, g[i][j] != 0})
+						}
+					}
+				}
+				patterns = append(patterns, p)
+			}
+		}
+	}
+	sort.SliceStable(patterns, func(x, y int) bool { return len(patterns[x].Squares) < len(patterns[y].Squares) })
+	return
+}
+
+// valueLoops numbers the loops formed by the squares of solution holding a or b from 1 up, giving each square the number of its loop,
+// or 0 if it holds neither.
+func valueLoops(solution [9][9]int, a, b int) (loops [9][9]int) {
+	n := 0
+	for i := 0; i < 9; i++ {
+		for j := 0; j < 9; j++ {
+			if (solution[i][j] != a && solution[i][j] != b) || loops[i][j] != 0 {
+				continue
+			}
+			n++
+			// Follow the loop, going along the row from a square in the column it was reached by, and down the column from one in
+			// the row it was reached by, until it comes back round.
+			r, c, alongRow := i, j, true
+			for loops[r][c] == 0 {
+				loops[r][c] = n
+				other := a + b - solution[r][c]
+				for k := 0; k < 9; k++ {
+					if alongRow && solution[r][k] == other {
+						c = k
+						break
+					}
+					if !alongRow && solution[k][c] == other {
+						r = k
+						break
+					}
+				}
+				alongRow = !alongRow
+			}
+		}
+	}
+	return
+}
+
+// loopSquares returns how many squares the loops in set, as bits of the loop numbers, hold.
+func loopSquares(loops [9][9]int, set int) (n int) {
+	for i := 0; i < 9; i++ {
+		for j := 0; j < 9; j++ {
+			if set&(1<<loops[i][j]) != 0 {
+				n++
+			}
+		}
+	}
+	return
+}
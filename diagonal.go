@@ -0,0 +1,134 @@
+// diagonal.go
+//
+// The X variant, in which each of the two long diagonals must also hold each of the values 1 through 9 exactly once.  When it is on,
+// a square on a diagonal clears its value from the rest of that diagonal as well as from its row, column and block, and each round's
+// analysis phase also looks at the two diagonals, for hidden singles and for values confined to where a diagonal crosses a block.
+package main
+
+const (
+	diagonalPointing technique = "diagonal-pointing"
+)
+
+// xVariant is set from the command line to solve an X-Sudoku.
+var xVariant bool
+
+// diagonalPos returns the square at position k along the main diagonal, top left to bottom right, or along the anti-diagonal, top
+// right to bottom left.
+func diagonalPos(anti bool, k int) (r, c int) {
+	if anti {
+		return k, 8 - k
+	}
+	return k, k
+}
+
+// sendDiagonalUpdates sends msg to the squares on the same diagonal as r, c that sendUpdates has not already reached through the block.
+func sendDiagonalUpdates(r, c int, msg updateMsg) {
+	for _, anti := range []bool{false, true} {
+		if (!anti && r != c) || (anti && r+c != 8) {
+			continue
+		}
+		for k := 0; k < 9; k++ {
+			i, j := diagonalPos(anti, k)
+			if blockOf[i][j] == blockOf[r][c] {
+				continue
+			}
+			if !peerFinal(r, c, i, j) {
+				msg.destR = i
+				msg.destC = j
+				bufferMsg(msg)
+			}
+		}
+	}
+}
+
+// inspectDiagonal analyses a diagonal, and is handed out to the square at the top of it: the main diagonal for 0, 0 and the
+// anti-diagonal for 0, 8.
+func inspectDiagonal(r, c int) (msgs []updateMsg) {
+	anti := r != c
+	for val := one; val <= nine; val <<= 1 {
+		var pos []int
+		for k := 0; k < 9; k++ {
+			if i, j := diagonalPos(anti, k); board[i][j].possVal.Has(val) {
+				pos = append(pos, k)
+			}
+		}
+		if len(pos) == 0 {
+			// The value has nowhere left to go on this diagonal.
+			if anti {
+				contradict("", "the anti-diagonal has no place for value %s", valuesString(val))
+			} else {
+				contradict("", "the main diagonal has no place for value %s", valuesString(val))
+			}
+			return
+		}
+		if len(pos) == 1 {
+			if i, j := diagonalPos(anti, pos[0]); !board[i][j].isFinal {
+				msgs = append(msgs, updateMsg{val, set, i, j, hiddenSingle})
+			}
+			continue
+		}
+		// With the usual blocks, each diagonal crosses three of them, in positions 0-2, 3-5 and 6-8.  If the value is confined to the
+		// part of the diagonal in one block, it can be cleared from the rest of the block.
+		blockAt := func(k int) int {
+			i, j := diagonalPos(anti, k)
+			return blockOf[i][j]
+		}
+		b := blockAt(pos[0])
+		sameBlock := true
+		for _, k := range pos {
+			sameBlock = sameBlock && blockAt(k) == b
+		}
+		if sameBlock {
+			for _, p := range blockSquares[b] {
+				if onDiag := (!anti && p.r == p.c) || (anti && p.r+p.c == 8); !onDiag {
+					msgs = append(msgs, updateMsg{val, clear, p.r, p.c, diagonalPointing})
+				}
+			}
+		}
+		// And the other way round: if within a block the diagonal crosses the value is confined to the diagonal, it can be cleared
+		// from the rest of the diagonal.
+		var looked [9]bool
+		for k := 0; k < 9; k++ {
+			b := blockAt(k)
+			if looked[b] {
+				continue
+			}
+			looked[b] = true
+			confined := true
+			for _, p := range blockSquares[b] {
+				if onDiag := (!anti && p.r == p.c) || (anti && p.r+p.c == 8); !onDiag && board[p.r][p.c].possVal.Has(val) {
+					confined = false
+					break
+				}
+			}
+			if !confined {
+				continue
+			}
+			for _, k := range pos {
+				if blockAt(k) != b {
+					i, j := diagonalPos(anti, k)
+					msgs = append(msgs, updateMsg{val, clear, i, j, diagonalPointing})
+				}
+			}
+		}
+	}
+	return
+}
+
+// diagonalsValid reports whether each of the two diagonals of the completed grid g holds each of the values 1 through 9 exactly once.
+func diagonalsValid(g [9][9]int) bool {
+	for _, anti := range []bool{false, true} {
+		var seen uint16
+		for k := 0; k < 9; k++ {
+			r, c := diagonalPos(anti, k)
+			if g[r][c] < 1 || g[r][c] > 9 {
+				return false
+			}
+			seen |= 1 << (g[r][c] - 1)
+		}
+		if seen != 0x1FF {
+			return false
+		}
+	}
+	return true
+}
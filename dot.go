@@ -0,0 +1,112 @@
+// dot.go
+//
+// A record of the most recent elimination made by one of the chain techniques, the remote pairs and the wings, kept so that once the
+// solve has finished it can be written out as a Graphviz graph with -dot.  The nodes are the squares of the chain, coloured as the
+// technique coloured them, the edges are the links between squares that see each other, and the squares the chain cleared hang off
+// the chain squares they see by dashed edges.  It is only read and written by the round looper, so it needs no lock.
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+)
+
+type chainNode struct {
+	pos     gridPos
+	possVal squareVal
+	colour  int // 1 or 2; the two colours hold the two sides of the chain
+}
+
+type chainGraph struct {
+	reason  technique
+	val     squareVal // the values cleared
+	nodes   []chainNode
+	links   [][2]int // pairs of indexes into nodes
+	cleared []chainNode
+}
+
+// lastChain is the most recent chain elimination of the solve, or nil if there has been none.
+var lastChain *chainGraph
+
+// noteChain records the chain made of the squares nodes, coloured by colour, as the most recent chain elimination if clearing val
+// from any of the squares in targets will change the board.  Any two of the chain squares that see each other are linked.
+func noteChain(reason technique, val squareVal, nodes []gridPos, colour []int, targets []gridPos) {
+	if disabled[reason] {
+		return
+	}
+	ch := &chainGraph{reason: reason, val: val}
+	for _, p := range targets {
+		if !board[p.r][p.c].isFinal && board[p.r][p.c].possVal.Has(val) {
+			ch.cleared = append(ch.cleared, chainNode{p, board[p.r][p.c].possVal, 0})
+		}
+	}
+	if len(ch.cleared) == 0 {
+		return
+	}
+	for k, p := range nodes {
+		ch.nodes = append(ch.nodes, chainNode{p, board[p.r][p.c].possVal, colour[k]})
+		for l := range nodes[:k] {
+			if seesSquare(p.r, p.c, nodes[l].r, nodes[l].c) {
+				ch.links = append(ch.links, [2]int{l, k})
+			}
+		}
+	}
+	lastChain = ch
+}
+
+// valuesString returns the symbols of the values in v, in order.
+func valuesString(v squareVal) string {
+	var sb strings.Builder
+	for k, sym := range symbols {
+		if v&(one<<k) != 0 {
+			sb.WriteRune(sym)
+		}
+	}
+	return sb.String()
+}
+
+func (n chainNode) id() string {
+	return fmt.Sprintf("r%dc%d", n.pos.r+1, n.pos.c+1)
+}
+
+// writeDot writes the most recent chain elimination to the file name as a Graphviz graph, for dot or neato to draw.
+func writeDot(name string) error {
+	if lastChain == nil {
+		return fmt.Errorf("no chain technique made an elimination, so there is nothing to write to %s", name)
+	}
+	f, err := os.Create(name)
+	if err != nil {
+		return fmt.Errorf("Unable to create file %s: %v", name, err)
+	}
+	w := bufio.NewWriter(f)
+	ch := lastChain
+	fillColours := [3]string{"white", "lightblue", "lightpink"}
+	fmt.Fprintln(w, "graph chain {")
+	fmt.Fprintf(w, "\tlabel=\"%s clears %s\";\n", ch.reason, valuesString(ch.val))
+	fmt.Fprintln(w, "\tnode [style=filled];")
+	for _, n := range ch.nodes {
+		fmt.Fprintf(w, "\t%s [label=\"%s\\n%s\", fillcolor=%s];\n", n.id(), n.id(), valuesString(n.possVal), fillColours[n.colour])
+	}
+	for _, l := range ch.links {
+		fmt.Fprintf(w, "\t%s -- %s;\n", ch.nodes[l[0]].id(), ch.nodes[l[1]].id())
+	}
+	for _, t := range ch.cleared {
+		fmt.Fprintf(w, "\t%s [label=\"%s\\n%s\", shape=box, fillcolor=%s];\n", t.id(), t.id(), valuesString(t.possVal), fillColours[0])
+		for _, n := range ch.nodes {
+			if seesSquare(t.pos.r, t.pos.c, n.pos.r, n.pos.c) {
+				fmt.Fprintf(w, "\t%s -- %s [style=dashed];\n", t.id(), n.id())
+			}
+		}
+	}
+	fmt.Fprintln(w, "}")
+	err = w.Flush()
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return fmt.Errorf("Error writing file %s: %v", name, err)
+	}
+	return nil
+}
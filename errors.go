@@ -0,0 +1,119 @@
+// errors.go
+//
+// The outcomes of reading and solving a puzzle, as error values a caller can tell apart with errors.Is and errors.As, and Solve, which
+// runs the solver and reports how it went in those terms.
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+var (
+	ErrNoSolution        = errors.New("the puzzle has no solution")
+	ErrMultipleSolutions = errors.New("the puzzle has more than one solution")
+	ErrStalled           = errors.New("the implemented techniques cannot solve the puzzle any further")
+	ErrInvalidInput      = errors.New("invalid input")
+)
+
+// InputError is a puzzle that could not be read.  Row and Col are the square at fault, counting from 0, or -1 when the fault is not
+// with a single row or column.  It matches ErrInvalidInput under errors.Is.
+type InputError struct {
+	Row, Col int
+	Msg      string
+}
+
+func (e *InputError) Error() string {
+	return e.Msg
+}
+
+func (e *InputError) Unwrap() error {
+	return ErrInvalidInput
+}
+
+func inputErrorf(row, col int, format string, a ...interface{}) *InputError {
+	return &InputError{row, col, fmt.Sprintf(format, a...)}
+}
+
+// ContradictionError says where the solver found that a puzzle contradicts itself.  Msg names the row, column, block, diagonal or cell,
+// such as "row 4 has no place for value 7", Reason is the technique whose deduction brought it about, or "" when it came to light in the
+// analysis of a unit, and Round is the round it was found in, or 0 for a fault in the givens.  It matches ErrNoSolution under errors.Is.
+type ContradictionError struct {
+	Msg    string
+	Reason string
+	Round  int
+}
+
+func (e *ContradictionError) Error() string {
+	switch {
+	case e.Reason != "":
+		return fmt.Sprintf("%s (%s, round %d)", e.Msg, e.Reason, e.Round)
+	case e.Round > 0:
+		return fmt.Sprintf("%s (round %d)", e.Msg, e.Round)
+	}
+	return e.Msg
+}
+
+func (e *ContradictionError) Unwrap() error {
+	return ErrNoSolution
+}
+
+var contradictionMu sync.Mutex
+
+// contradiction is the contradiction that ended the last solve, or nil.
+var contradiction *ContradictionError
+
+// contradict records a contradiction and sets noSolution.  Once one is found the rest usually follow from it, so only the earliest is
+// kept.  Of several found in the same round, by different square monitors, one that names the technique behind it is kept over one that
+// does not, and otherwise the one whose message sorts first, so that the same one is reported on every run.
+func contradict(reason technique, format string, a ...interface{}) {
+	e := &ContradictionError{fmt.Sprintf(format, a...), string(reason), roundsRun}
+	better := func(old *ContradictionError) bool {
+		switch {
+		case e.Round != old.Round:
+			return e.Round < old.Round
+		case (e.Reason == "") != (old.Reason == ""):
+			return e.Reason != ""
+		}
+		return e.Error() < old.Error()
+	}
+	contradictionMu.Lock()
+	if contradiction == nil || better(contradiction) {
+		contradiction = e
+	}
+	contradictionMu.Unlock()
+	noSolution.Store(true)
+}
+
+// noSolutionError returns the contradiction that ended the last solve, or ErrNoSolution if none was recorded.
+func noSolutionError() error {
+	if contradiction != nil {
+		return contradiction
+	}
+	return ErrNoSolution
+}
+
+// Solve solves the puzzle g, with 0 for an unknown square, and returns the grid as far as the solver got, with 0 for the squares it
+// could not finalize.  err is nil when the puzzle was solved, ErrNoSolution when it contradicts itself (a *ContradictionError saying
+// where, if the solver found it), and otherwise ErrStalled, or ErrMultipleSolutions when the solver stalled because more than one solution
+// fits.  When the solver finds the puzzle contradicts itself it returns g unchanged rather than the board, which by then holds the
+// contradiction, or when the givens already conflict, was never set up.  Only one solve can run at a time.
+func Solve(g [9][9]int) (solution [9][9]int, err error) {
+	solve(g, solveOptions{})
+	if noSolution.Load() {
+		return g, noSolutionError()
+	}
+	solution = boardGrid()
+	if !stalled {
+		return solution, nil
+	}
+	// The solver never guesses, so to tell the two apart it takes a search.
+	switch len(AllSolutions(g, 2)) {
+	case 0:
+		return solution, ErrNoSolution
+	case 1:
+		return solution, ErrStalled
+	}
+	return solution, ErrMultipleSolutions
+}
@@ -0,0 +1,79 @@
+// events.go
+//
+// The deductions of a solve as newline-delimited JSON, for solve -events, to feed a log pipeline or replay a solve elsewhere.  It is the
+// same history the HTML walkthrough is built from, one object to a line, in the order the moves were applied.
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+)
+
+// solveEvent is one line of solve -events: a value cleared from a square, or a square set to a value, by a technique in a round.  Rows
+// and columns count from 1.
+type solveEvent struct {
+	Round     int    `json:"round"`
+	R         int    `json:"r"`
+	C         int    `json:"c"`
+	Op        string `json:"op"`
+	Value     int    `json:"value"`
+	Technique string `json:"technique"`
+}
+
+// writeEvents writes the deductions of the last solve to w, one event to a line.  A square set by a technique gives a set event; any
+// other move gives a clear event for each value it cleared, followed by a set event from naked-single if that left only one.  The givens,
+// and any state a solve was resumed from, are not deductions and are left out, but the clears a finalized square makes in the squares it
+// sees are included, under solved-peer, so that replaying the events onto the puzzle reaches the board the solve did.
+func writeEvents(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	for _, m := range movesInOrder() {
+		if m.reason == given || m.reason == savedState {
+			continue
+		}
+		ev := solveEvent{Round: m.round, R: m.r + 1, C: m.c + 1, Op: "set", Technique: string(m.reason)}
+		if m.after.IsSingle() && !m.before.IsSingle() && m.solvedBy != nakedSingle {
+			ev.Value = m.after.Values()[0]
+			if err := enc.Encode(ev); err != nil {
+				return err
+			}
+			continue
+		}
+		ev.Op = "clear"
+		for _, v := range m.before.Remove(m.after).Values() {
+			ev.Value = v
+			if err := enc.Encode(ev); err != nil {
+				return err
+			}
+		}
+		if m.solvedBy == nakedSingle && m.after.IsSingle() && !m.before.IsSingle() {
+			ev.Op, ev.Value, ev.Technique = "set", m.after.Values()[0], string(nakedSingle)
+			if err := enc.Encode(ev); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// writeEventsFile writes the events of the last solve to the file name.
+func writeEventsFile(name string) error {
+	f, err := os.Create(name)
+	if err != nil {
+		return fmt.Errorf("Unable to create file %s: %v", name, err)
+	}
+	w := bufio.NewWriter(f)
+	err = writeEvents(w)
+	if ferr := w.Flush(); err == nil {
+		err = ferr
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return fmt.Errorf("Error writing file %s: %v", name, err)
+	}
+	return nil
+}
@@ -0,0 +1,151 @@
+// explain.go
+//
+// A walkthrough of a solve as an HTML page, for solve -explain-html.  The page is built from the history of moves the solve recorded:
+// they are replayed in order onto an empty board, and each deduction gets a step of its own, saying which technique made it and
+// showing the board after it, with the square it changed highlighted and the values still possible in each open square pencilled in.
+// Finalizing a square clears its value from every square it sees in the next phase, and those clears are applied without steps of
+// their own, as are the givens.  solve -format html draws just the final board in the same way.
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"html"
+	"io"
+	"os"
+)
+
+const explainStyle = `body { font-family: sans-serif; max-width: 44em; margin: 2em auto; }
+table.board { border-collapse: collapse; border: 3px solid #000; margin: 0.5em 0 1.5em; }
+table.board td { width: 42px; height: 42px; padding: 0; border: 1px solid #aaa; text-align: center; font-size: 22px; }
+table.board td.bt { border-top: 3px solid #000; }
+table.board td.bl { border-left: 3px solid #000; }
+td.given { font-weight: bold; }
+td.solved { color: #1f4eb4; }
+td.changed { background: #ffe680; }
+div.cands { display: grid; grid-template-columns: repeat(3, 1fr); font-size: 10px; line-height: 13px; color: #666; }
+`
+
+// writeBoardHTML writes state to w as a web page for solve -format html, with title above the board and outcome below it.  The values
+// still possible in each open square are pencilled in, each in its own place in a three by three grid, as most Sudoku apps show them.
+func writeBoardHTML(w io.Writer, grid [9][9]int, state [9][9]squareVal, title, outcome string) {
+	fmt.Fprintf(w, "<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n<title>%s</title>\n<style>\n%s</style>\n</head>\n<body>\n",
+		html.EscapeString(title), explainStyle)
+	fmt.Fprintf(w, "<h1>%s</h1>\n", html.EscapeString(title))
+	writeExplainBoard(w, grid, state, -1, -1)
+	fmt.Fprintf(w, "<p>%s</p>\n</body>\n</html>\n", html.EscapeString(outcome))
+}
+
+// writeExplainHTML writes the walkthrough of the last solve of grid to the file name.  title heads the page, and outcome ends it.
+func writeExplainHTML(name string, grid [9][9]int, title, outcome string) error {
+	f, err := os.Create(name)
+	if err != nil {
+		return fmt.Errorf("Unable to create file %s: %v", name, err)
+	}
+	w := bufio.NewWriter(f)
+	writeExplanation(w, grid, title, outcome)
+	err = w.Flush()
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return fmt.Errorf("Error writing file %s: %v", name, err)
+	}
+	return nil
+}
+
+func writeExplanation(w io.Writer, grid [9][9]int, title, outcome string) {
+	moves := movesInOrder()
+	fmt.Fprintf(w, "<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n<title>%s</title>\n<style>\n%s</style>\n</head>\n<body>\n",
+		html.EscapeString(title), explainStyle)
+	fmt.Fprintf(w, "<h1>%s</h1>\n", html.EscapeString(title))
+	state := gridState(grid)
+	fmt.Fprintf(w, "<h2>The puzzle</h2>\n")
+	writeExplainBoard(w, grid, state, -1, -1)
+	step := 0
+	for _, m := range moves {
+		state[m.r][m.c] = m.after
+		if m.reason == given || m.reason == solvedPeer {
+			continue
+		}
+		step++
+		fmt.Fprintf(w, "<h2>Step %d, round %d</h2>\n<p>", step, m.round)
+		if m.after.IsSingle() && m.solvedBy != nakedSingle {
+			fmt.Fprintf(w, "%s: row %d column %d is %s.", m.reason, m.r+1, m.c+1, html.EscapeString(valuesString(m.after)))
+		} else {
+			fmt.Fprintf(w, "%s: %s cleared from row %d column %d, leaving %s.", m.reason, html.EscapeString(valuesString(m.before&^m.after)),
+				m.r+1, m.c+1, html.EscapeString(valuesString(m.after)))
+		}
+		fmt.Fprintf(w, "</p>\n")
+		writeExplainBoard(w, grid, state, m.r, m.c)
+	}
+	fmt.Fprintf(w, "<p>%s</p>\n</body>\n</html>\n", html.EscapeString(outcome))
+}
+
+// gridState returns the possible values of every square of grid before any deduction: only its value for a given, and any value
+// for the rest.
+func gridState(grid [9][9]int) (state [9][9]squareVal) {
+	for i := 0; i < 9; i++ {
+		for j := 0; j < 9; j++ {
+			state[i][j] = blank
+			if grid[i][j] != 0 {
+				state[i][j] = one << (grid[i][j] - 1)
+			}
+		}
+	}
+	return
+}
+
+// writeExplainBoard writes state as an HTML table, with the square r, c highlighted, the givens of grid in bold, heavy lines around the
+// blocks, and the possible values of each open square in small type.
+func writeExplainBoard(w io.Writer, grid [9][9]int, state [9][9]squareVal, r, c int) {
+	fmt.Fprintf(w, "<table class=\"board\">\n")
+	for i := 0; i < 9; i++ {
+		fmt.Fprintf(w, "<tr>")
+		for j := 0; j < 9; j++ {
+			var class []byte
+			addClass := func(name string) {
+				if len(class) > 0 {
+					class = append(class, ' ')
+				}
+				class = append(class, name...)
+			}
+			if i > 0 && blockOf[i-1][j] != blockOf[i][j] {
+				addClass("bt")
+			}
+			if j > 0 && blockOf[i][j-1] != blockOf[i][j] {
+				addClass("bl")
+			}
+			switch {
+			case grid[i][j] != 0:
+				addClass("given")
+			case state[i][j].IsSingle():
+				addClass("solved")
+			}
+			if i == r && j == c {
+				addClass("changed")
+			}
+			if len(class) > 0 {
+				fmt.Fprintf(w, "<td class=\"%s\">", class)
+			} else {
+				fmt.Fprintf(w, "<td>")
+			}
+			if state[i][j].IsSingle() {
+				fmt.Fprintf(w, "%s", html.EscapeString(valuesString(state[i][j])))
+			} else {
+				fmt.Fprintf(w, "<div class=\"cands\">")
+				for k, sym := range symbols {
+					if state[i][j]&(one<<k) != 0 {
+						fmt.Fprintf(w, "<div>%s</div>", html.EscapeString(string(sym)))
+					} else {
+						fmt.Fprintf(w, "<div></div>")
+					}
+				}
+				fmt.Fprintf(w, "</div>")
+			}
+			fmt.Fprintf(w, "</td>")
+		}
+		fmt.Fprintf(w, "</tr>\n")
+	}
+	fmt.Fprintf(w, "</table>\n")
+}
@@ -0,0 +1,68 @@
+// generate.go
+//
+// Making new puzzles.  A completed grid is made at random, and then givens are taken away one at a time, in random order, so long as
+// the puzzle left still has only the one solution.  Both steps rely on the backtracking search in AllSolutions rather than on the
+// solver, so a generated puzzle may need techniques the solver does not have.
+package main
+
+import (
+	"fmt"
+	"math/rand"
+)
+
+// randomSolution returns a completed grid chosen at random.  The three blocks on the diagonal share no row or column, so they can be
+// filled in independently, each with a shuffle of 1 through 9; the search then completes the rest.
+func randomSolution(rng *rand.Rand) [9][9]int {
+	var g [9][9]int
+	for r, c := 0, 0; r < 9 && c < 9; r, c = r+blockH, c+blockW {
+		for k, v := range rng.Perm(9) {
+			g[r+k/blockW][c+k%blockW] = v + 1
+		}
+	}
+	return AllSolutions(g, 1)[0]
+}
+
+// Generate returns a new puzzle, with 0 for an unknown square, that has a unique solution and between minGivens and maxGivens givens.
+// Each attempt starts from a new completed grid and takes givens away until there are minGivens left, or until no more can go without
+// losing uniqueness.  If none of the attempts ends with at most maxGivens, it returns an error.
+func Generate(rng *rand.Rand, minGivens, maxGivens, attempts int) (puzzle [9][9]int, err error) {
+	return GenerateBalanced(rng, minGivens, maxGivens, attempts, 0)
+}
+
+// GenerateBalanced is Generate, keeping at least minPerUnit givens in every row, column and block, so that the givens are spread over
+// the grid rather than clustered.  A given is only taken away if its row, column and block would all still have that many.
+func GenerateBalanced(rng *rand.Rand, minGivens, maxGivens, attempts, minPerUnit int) (puzzle [9][9]int, err error) {
+	if minGivens > maxGivens || maxGivens > 81 || 9*minPerUnit > maxGivens {
+		return puzzle, fmt.Errorf("there can be no puzzle with between %d and %d givens and %d in each row, column and block", minGivens,
+			maxGivens, minPerUnit)
+	}
+	for a := 0; a < attempts; a++ {
+		puzzle = randomSolution(rng)
+		n := 81
+		rows, cols, blocks := [9]int{9, 9, 9, 9, 9, 9, 9, 9, 9}, [9]int{9, 9, 9, 9, 9, 9, 9, 9, 9}, [9]int{9, 9, 9, 9, 9, 9, 9, 9, 9}
+		for _, k := range rng.Perm(81) {
+			if n <= minGivens {
+				break
+			}
+			i, j := k/9, k%9
+			b := blockOf[i][j]
+			if rows[i] <= minPerUnit || cols[j] <= minPerUnit || blocks[b] <= minPerUnit {
+				continue
+			}
+			v := puzzle[i][j]
+			puzzle[i][j] = 0
+			if len(AllSolutions(puzzle, 2)) == 1 {
+				n--
+				rows[i]--
+				cols[j]--
+				blocks[b]--
+			} else {
+				puzzle[i][j] = v
+			}
+		}
+		if n <= maxGivens {
+			return puzzle, nil
+		}
+	}
+	return [9][9]int{}, fmt.Errorf("no puzzle with between %d and %d givens was found in %d attempts", minGivens, maxGivens, attempts)
+}
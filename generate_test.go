@@ -0,0 +1,68 @@
+package main
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// puzzleWithSolutions returns a puzzle with exactly k solutions, made from the seed the same way on every run.  Each attempt starts from
+// a new completed grid and takes away every given it can, in random order, without the puzzle having more than k solutions.  The count
+// can jump past k when a given goes, so an attempt may end with fewer, and the next grid is tried.
+func puzzleWithSolutions(t *testing.T, seed int64, k int) [9][9]int {
+	t.Helper()
+	savedBranch := branch
+	branch = branchMRV
+	defer func() { branch = savedBranch }()
+	rng := rand.New(rand.NewSource(seed))
+	for a := 0; a < 100; a++ {
+		puzzle := randomSolution(rng)
+		count := 1
+		for _, p := range rng.Perm(81) {
+			i, j := p/9, p%9
+			v := puzzle[i][j]
+			puzzle[i][j] = 0
+			if n := len(AllSolutions(puzzle, k+1)); n <= k {
+				count = n
+			} else {
+				puzzle[i][j] = v
+			}
+		}
+		if count == k {
+			return puzzle
+		}
+	}
+	t.Fatalf("no puzzle with %d solutions was found from seed %d", k, seed)
+	return [9][9]int{}
+}
+
+func TestAllSolutionsCounts(t *testing.T) {
+	for _, k := range []int{1, 2, 3} {
+		puzzle := puzzleWithSolutions(t, 7, k)
+		if again := puzzleWithSolutions(t, 7, k); again != puzzle {
+			t.Errorf("k=%d: the same seed made two different puzzles", k)
+		}
+		solutions := AllSolutions(puzzle, 10)
+		if len(solutions) != k {
+			t.Fatalf("k=%d: AllSolutions found %d solutions", k, len(solutions))
+		}
+		for a := range solutions {
+			if !givensConsistent(solutions[a]) {
+				t.Errorf("k=%d: solution %d breaks the rules", k, a+1)
+			}
+			for b := a + 1; b < len(solutions); b++ {
+				if solutions[a] == solutions[b] {
+					t.Errorf("k=%d: solutions %d and %d are the same", k, a+1, b+1)
+				}
+			}
+		}
+		// A search that stops at fewer than there are finds that many, as the uniqueness checks rely on.
+		if n := len(AllSolutions(puzzle, 1)); n != 1 {
+			t.Errorf("k=%d: AllSolutions with a max of 1 found %d", k, n)
+		}
+		if k > 1 {
+			if n := len(AllSolutions(puzzle, 2)); n != 2 {
+				t.Errorf("k=%d: AllSolutions with a max of 2 found %d", k, n)
+			}
+		}
+	}
+}
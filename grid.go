@@ -0,0 +1,322 @@
+// grid.go
+//
+// Helpers that work on a plain 9x9 grid of ints, with 1 through 9 for a known square and 0 for an unknown one.  These are independent
+// of the square monitors and the round looper, so they can be used to check a grid that did not come from the solver at all.  The
+// blocks are those of blockOf, which are the usual 3x3 blocks unless a Jigsaw puzzle has been read.
+package main
+
+import (
+	"fmt"
+	"math/rand"
+)
+
+// minClues is the fewest givens any Sudoku with a unique solution has been found to have.  A puzzle with fewer is under-constrained.
+const minClues = 17
+
+// countGivens returns the number of squares of g that have an initial value.
+func countGivens(g [9][9]int) (n int) {
+	for i := 0; i < 9; i++ {
+		for j := 0; j < 9; j++ {
+			if g[i][j] != 0 {
+				n++
+			}
+		}
+	}
+	return
+}
+
+// givensPerUnit returns the fewest and the most givens of g in any row, column or block.
+func givensPerUnit(g [9][9]int) (fewest, most int) {
+	var rowN, colN, blockN [9]int
+	for i := 0; i < 9; i++ {
+		for j := 0; j < 9; j++ {
+			if g[i][j] != 0 {
+				rowN[i]++
+				colN[j]++
+				blockN[blockOf[i][j]]++
+			}
+		}
+	}
+	fewest, most = 9, 0
+	for _, counts := range [][9]int{rowN, colN, blockN} {
+		for _, n := range counts {
+			fewest, most = min(fewest, n), max(most, n)
+		}
+	}
+	return
+}
+
+// estimateDifficulty guesses how hard the puzzle g is from its givens alone, without solving it: easy, medium, hard or very hard.
+// The bands are set by the newspaper puzzles, with 32 givens on a Monday and 25 or 26 at the end of the week, and a puzzle with a
+// row, column or block that has no givens at all is put one band harder.
+func estimateDifficulty(g [9][9]int) string {
+	levels := []string{"easy", "medium", "hard", "very hard"}
+	n := countGivens(g)
+	level := 3
+	switch {
+	case n >= 30:
+		level = 0
+	case n >= 27:
+		level = 1
+	case n >= 24:
+		level = 2
+	}
+	if fewest, _ := givensPerUnit(g); fewest == 0 && level < 3 {
+		level++
+	}
+	return levels[level]
+}
+
+// MarkGivens returns grid with each square that given marks as a given negated, so that -5 is a given 5, for tools that carry a solution
+// and its clues in a single grid.  A square marked as given but holding 0 stays 0, as it has nothing to negate.
+func MarkGivens(grid [9][9]int, given [9][9]bool) (marked [9][9]int) {
+	for i := 0; i < 9; i++ {
+		for j := 0; j < 9; j++ {
+			marked[i][j] = grid[i][j]
+			if given[i][j] {
+				marked[i][j] = -grid[i][j]
+			}
+		}
+	}
+	return
+}
+
+// SplitGivens undoes MarkGivens, returning the grid with every value positive and which of its squares were negative, and so given.
+func SplitGivens(marked [9][9]int) (grid [9][9]int, given [9][9]bool) {
+	for i := 0; i < 9; i++ {
+		for j := 0; j < 9; j++ {
+			grid[i][j] = marked[i][j]
+			if marked[i][j] < 0 {
+				grid[i][j], given[i][j] = -marked[i][j], true
+			}
+		}
+	}
+	return
+}
+
+// IsValidSolution reports whether g is completely filled in and every row, column and block holds each of the values 1 through 9
+// exactly once.
+func IsValidSolution(g [9][9]int) bool {
+	var rowSeen, colSeen, blockSeen [9]uint16
+	for i := 0; i < 9; i++ {
+		for j := 0; j < 9; j++ {
+			v := g[i][j]
+			if v < 1 || v > 9 {
+				return false
+			}
+			bit := uint16(1) << (v - 1)
+			b := blockOf[i][j]
+			if rowSeen[i]&bit != 0 || colSeen[j]&bit != 0 || blockSeen[b]&bit != 0 {
+				return false
+			}
+			rowSeen[i] |= bit
+			colSeen[j] |= bit
+			blockSeen[b] |= bit
+		}
+	}
+	return true
+}
+
+// givensConsistent reports whether every value of g is in the range 0 through 9, and no value 1 through 9 appears more than once in
+// any row, column or block.  A grid that fails this has no solution at all.
+func givensConsistent(g [9][9]int) bool {
+	return givensConflict(g) == ""
+}
+
+// givensConflict describes the first fault givensConsistent would find with g, in row order, such as "row 4 has 7 twice", or returns
+// "" if there is none.
+func givensConflict(g [9][9]int) string {
+	var rowSeen, colSeen, blockSeen [9]uint16
+	for i := 0; i < 9; i++ {
+		for j := 0; j < 9; j++ {
+			v := g[i][j]
+			if v < 0 || v > 9 {
+				return fmt.Sprintf("cell R%dC%d has %d, which is not a value", i+1, j+1, v)
+			} else if v == 0 {
+				continue
+			}
+			bit := uint16(1) << (v - 1)
+			b := blockOf[i][j]
+			switch {
+			case rowSeen[i]&bit != 0:
+				return fmt.Sprintf("row %d has %c twice", i+1, symbols[v-1])
+			case colSeen[j]&bit != 0:
+				return fmt.Sprintf("column %d has %c twice", j+1, symbols[v-1])
+			case blockSeen[b]&bit != 0:
+				return fmt.Sprintf("block %d has %c twice", b+1, symbols[v-1])
+			}
+			rowSeen[i] |= bit
+			colSeen[j] |= bit
+			blockSeen[b] |= bit
+		}
+	}
+	return ""
+}
+
+// branchRule chooses the empty square the search in AllSolutions tries each value in next.  It makes no difference to which solutions
+// are found, only to how quickly, and to the order they are found in.
+type branchRule string
+
+const (
+	branchMRV    branchRule = "mrv"    // the square with the fewest values left that fit, so that dead ends are found as early as possible
+	branchFirst  branchRule = "first"  // the first empty square in row order
+	branchRandom branchRule = "random" // an empty square chosen at random, using branchRand
+)
+
+var branchRules = []branchRule{branchMRV, branchFirst, branchRandom}
+
+// branch is the rule the search uses, set by -branch.  branchRand has a fixed seed, so that even the random rule makes the same
+// choices on every run unless it is reseeded.
+var branch = branchMRV
+var branchRand = rand.New(rand.NewSource(1))
+
+// AllSolutions returns the solutions of the puzzle g, found by a plain backtracking search, stopping once max of them have been found.
+// Unlike the solver, this guesses, so it is only meant for checking a puzzle, for instance to see why it has more than one solution.
+// The search keeps to the diagonals in the X variant and to the cages of a Killer Sudoku, as well as to the rows, columns and blocks,
+// and branches on the square chosen by branch.
+func AllSolutions(g [9][9]int, max int) (solutions [][9][9]int) {
+	if !givensConsistent(g) {
+		return nil
+	}
+	var rowUsed, colUsed, blockUsed [9]uint16
+	var diagUsed [2]uint16
+	// cageOf[i][j] is one more than the index in cages of the cage holding square i, j, or 0 if it is in none.  cageTotal and cageLeft
+	// are the sum of the values placed in each cage so far and the number of its squares still empty.
+	var cageOf [9][9]int
+	cageUsed := make([]uint16, len(cages))
+	cageTotal := make([]int, len(cages))
+	cageLeft := make([]int, len(cages))
+	for k, cg := range cages {
+		cageLeft[k] = len(cg.squares)
+		for _, p := range cg.squares {
+			cageOf[p.r][p.c] = k + 1
+		}
+	}
+	fits := func(i, j, v int) bool {
+		bit := uint16(1) << (v - 1)
+		if rowUsed[i]&bit != 0 || colUsed[j]&bit != 0 || blockUsed[blockOf[i][j]]&bit != 0 {
+			return false
+		}
+		if xVariant && ((i == j && diagUsed[0]&bit != 0) || (i+j == 8 && diagUsed[1]&bit != 0)) {
+			return false
+		}
+		if k := cageOf[i][j] - 1; k >= 0 {
+			// The squares left after this one must be able to make up the rest of the sum with different values.
+			left, rest := cageLeft[k]-1, cages[k].sum-cageTotal[k]-v
+			if cageUsed[k]&bit != 0 || rest < left*(left+1)/2 || rest > left*(19-left)/2 {
+				return false
+			}
+		}
+		return true
+	}
+	mark := func(i, j, v int, on bool) {
+		bit := uint16(1) << (v - 1)
+		flip := func(used *uint16) {
+			if on {
+				*used |= bit
+			} else {
+				*used &^= bit
+			}
+		}
+		flip(&rowUsed[i])
+		flip(&colUsed[j])
+		flip(&blockUsed[blockOf[i][j]])
+		if xVariant && i == j {
+			flip(&diagUsed[0])
+		}
+		if xVariant && i+j == 8 {
+			flip(&diagUsed[1])
+		}
+		if k := cageOf[i][j] - 1; k >= 0 {
+			flip(&cageUsed[k])
+			if on {
+				cageTotal[k] += v
+				cageLeft[k]--
+			} else {
+				cageTotal[k] -= v
+				cageLeft[k]++
+			}
+		}
+	}
+	for i := 0; i < 9; i++ {
+		for j := 0; j < 9; j++ {
+			if v := g[i][j]; v != 0 {
+				if !fits(i, j, v) {
+					return nil
+				}
+				mark(i, j, v, true)
+			}
+		}
+	}
+
+	// next returns the empty square to branch on, or false once there are none left.
+	next := func() (i, j int, ok bool) {
+		var empty []gridPos
+		fewest := 10
+		for r := 0; r < 9; r++ {
+			for c := 0; c < 9; c++ {
+				if g[r][c] != 0 {
+					continue
+				}
+				switch branch {
+				case branchFirst:
+					return r, c, true
+				case branchRandom:
+					empty = append(empty, gridPos{r, c})
+				default:
+					n := 0
+					for v := 1; v <= 9; v++ {
+						if fits(r, c, v) {
+							n++
+						}
+					}
+					if n < fewest {
+						i, j, ok, fewest = r, c, true, n
+					}
+					if n == 0 {
+						// A dead end; there is no need to look any further.
+						return
+					}
+				}
+			}
+		}
+		if len(empty) > 0 {
+			p := empty[branchRand.Intn(len(empty))]
+			return p.r, p.c, true
+		}
+		return
+	}
+	var backtrack func() bool
+	backtrack = func() bool {
+		// Returns true once max solutions have been found, to unwind the search.
+		i, j, ok := next()
+		if !ok {
+			solutions = append(solutions, g)
+			return len(solutions) >= max
+		}
+		for v := 1; v <= 9; v++ {
+			if !fits(i, j, v) {
+				continue
+			}
+			g[i][j] = v
+			mark(i, j, v, true)
+			done := backtrack()
+			g[i][j] = 0
+			mark(i, j, v, false)
+			if done {
+				return true
+			}
+		}
+		return false
+	}
+	if max > 0 {
+		backtrack()
+	}
+	return
+}
+
+// HasSolution reports whether the puzzle g has any solution at all.  The search stops at the first solution it finds, so this is much
+// quicker than counting them on a puzzle with many.
+func HasSolution(g [9][9]int) bool {
+	return len(AllSolutions(g, 1)) > 0
+}
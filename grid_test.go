@@ -0,0 +1,137 @@
+package main
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func TestMarkGivensRoundTrip(t *testing.T) {
+	puzzle, _, err := readBoard("XWing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	solution := AllSolutions(puzzle, 1)[0]
+	var given, none, all [9][9]bool
+	for i := 0; i < 9; i++ {
+		for j := 0; j < 9; j++ {
+			given[i][j] = puzzle[i][j] != 0
+			all[i][j] = true
+		}
+	}
+	for _, tc := range []struct {
+		name  string
+		grid  [9][9]int
+		given [9][9]bool
+	}{
+		{"the solution with the puzzle's givens", solution, given},
+		{"the puzzle with its givens", puzzle, given},
+		{"the solution with no givens", solution, none},
+		{"the solution all given", solution, all},
+		{"an empty grid with no givens", [9][9]int{}, none},
+	} {
+		marked := MarkGivens(tc.grid, tc.given)
+		for i := 0; i < 9; i++ {
+			for j := 0; j < 9; j++ {
+				if (marked[i][j] < 0) != (tc.given[i][j] && tc.grid[i][j] != 0) {
+					t.Errorf("%s: R%dC%d is marked %d", tc.name, i+1, j+1, marked[i][j])
+				}
+			}
+		}
+		grid, given := SplitGivens(marked)
+		if grid != tc.grid || given != tc.given {
+			t.Errorf("%s: SplitGivens(MarkGivens(g, givens)) does not give back g and givens", tc.name)
+		}
+	}
+
+	// A square marked as given but holding 0 has nothing to negate, so it comes back not given.
+	var first [9][9]bool
+	first[0][0] = true
+	if grid, given := SplitGivens(MarkGivens([9][9]int{}, first)); grid != [9][9]int{} || given[0][0] {
+		t.Errorf("an empty square marked as given came back given, or not empty")
+	}
+}
+
+func TestIsValidSolution(t *testing.T) {
+	puzzle, _, err := readBoard("XWing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	solution := AllSolutions(puzzle, 1)[0]
+	// Each change breaks only the one rule: two squares swapped within a column and a block leave both whole but repeat a value in
+	// each of their rows, two swapped within a row and a block repeat one in each of their columns, and two rows swapped from different
+	// bands leave every row and column whole but repeat values in the blocks.
+	rows, cols, blocks, zero, ten := solution, solution, solution, solution, solution
+	rows[0][0], rows[1][0] = rows[1][0], rows[0][0]
+	cols[0][0], cols[0][1] = cols[0][1], cols[0][0]
+	blocks[0], blocks[3] = blocks[3], blocks[0]
+	zero[4][4] = 0
+	ten[8][8] = 10
+	for _, tc := range []struct {
+		name     string
+		grid     [9][9]int
+		ok       bool
+		conflict string // how givensConflict's description of the grid starts, to show which rule is broken
+	}{
+		{"the solution", solution, true, ""},
+		{"a value repeated in a row", rows, false, "row "},
+		{"a value repeated in a column", cols, false, "column "},
+		{"a value repeated in a block", blocks, false, "block "},
+		{"a square left 0", zero, false, ""},
+		{"a square out of range", ten, false, "cell "},
+		{"the puzzle", puzzle, false, ""},
+		{"an empty grid", [9][9]int{}, false, ""},
+	} {
+		if ok := IsValidSolution(tc.grid); ok != tc.ok {
+			t.Errorf("%s: IsValidSolution is %v", tc.name, ok)
+		}
+		if conflict := givensConflict(tc.grid); !strings.HasPrefix(conflict, tc.conflict) || (tc.conflict == "") != (conflict == "") {
+			t.Errorf("%s: givensConflict says %q", tc.name, conflict)
+		}
+	}
+}
+
+// BenchmarkAllSolutions times checking uniqueness, as generate and -selfcheck do, with each branch rule.  The hard puzzles are those the
+// solver stalls on without -advanced.  The random rule does not finish them, so it is timed only on a puzzle made from a solution by
+// emptying 40 squares, which all three rules finish and which has more than one solution.
+func BenchmarkAllSolutions(b *testing.B) {
+	var hard [][9][9]int
+	for _, name := range []string{"Inkala", "Escargot", "AlternatingChain1", "AlternatingChain2", "AlternatingChain3", "SueDeCoq",
+		"AlignedPair"} {
+		grid, _, err := readBoard(name)
+		if err != nil {
+			b.Fatal(err)
+		}
+		hard = append(hard, grid)
+	}
+	thinned := AllSolutions(hard[0], 1)[0]
+	for _, k := range rand.New(rand.NewSource(1)).Perm(81)[:40] {
+		thinned[k/9][k%9] = 0
+	}
+	if n := len(AllSolutions(thinned, 2)); n != 2 {
+		b.Fatalf("the thinned puzzle has %d solutions, not more than one", n)
+	}
+	defer func() { branch = branchMRV }()
+	for _, rule := range branchRules {
+		for _, set := range []struct {
+			name    string
+			puzzles [][9][9]int
+		}{
+			{"hard", hard},
+			{"thinned", [][9][9]int{thinned}},
+		} {
+			if rule == branchRandom && set.name == "hard" {
+				continue
+			}
+			b.Run(string(rule)+"/"+set.name, func(b *testing.B) {
+				branch = rule
+				branchRand.Seed(1)
+				for n := 0; n < b.N; n++ {
+					for _, p := range set.puzzles {
+						AllSolutions(p, 2)
+					}
+				}
+			})
+		}
+	}
+}
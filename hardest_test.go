@@ -0,0 +1,65 @@
+package main
+
+import (
+	"io"
+	"testing"
+)
+
+// The published solutions of two of the best known extreme puzzles, as 81 digits in row order.
+var publishedSolutions = map[string]string{
+	// Arto Inkala's puzzle of 2010, billed as the world's hardest.
+	"Inkala": "812753649943682175675491283154237896369845721287169534521974368438526917796318452",
+	// Arto Inkala's AI Escargot of 2006.
+	"Escargot": "162857493534129678789643521475312986913586742628794135356478219241935867897261354",
+}
+
+// TestHardestPuzzles checks that each of the extreme puzzles has exactly the one solution, the published one, and that the solver,
+// which never guesses, stalls on it without making a deduction that contradicts that solution.  The stall is expected: none of the
+// techniques here, even with -advanced, gets a foothold on either puzzle, and Inkala.out and Escargot.out record it.
+func TestHardestPuzzles(t *testing.T) {
+	savedAdvanced := advanced
+	advanced = true
+	defer func() { advanced = savedAdvanced }()
+	for name, digits := range publishedSolutions {
+		puzzle, _, err := readBoard(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		published, err := parseGridString(digits)
+		if err != nil {
+			t.Fatalf("%s: the published solution: %v", name, err)
+		}
+		if !IsValidSolution(published) {
+			t.Fatalf("%s: the published solution is not a legal solution", name)
+		}
+		for i := 0; i < 9; i++ {
+			for j := 0; j < 9; j++ {
+				if puzzle[i][j] != 0 && puzzle[i][j] != published[i][j] {
+					t.Fatalf("%s: the published solution does not hold the given at R%dC%d", name, i+1, j+1)
+				}
+			}
+		}
+		solutions := AllSolutions(puzzle, 2)
+		if len(solutions) != 1 {
+			t.Fatalf("%s: the search finds %d solutions, not 1", name, len(solutions))
+		}
+		if solutions[0] != published {
+			t.Errorf("%s: the search finds a solution other than the published one", name)
+		}
+
+		solve(puzzle, solveOptions{out: io.Discard, solution: &published})
+		if !stalled || noSolution.Load() {
+			t.Errorf("%s: the solver was expected to stall", name)
+		}
+		if selfCheckFailed() {
+			t.Errorf("%s: the solver made deductions that contradict the published solution", name)
+		}
+		for i := 0; i < 9; i++ {
+			for j := 0; j < 9; j++ {
+				if !board[i][j].possVal.Has(one << (published[i][j] - 1)) {
+					t.Errorf("%s: the solver ruled out the published value at R%dC%d", name, i+1, j+1)
+				}
+			}
+		}
+	}
+}
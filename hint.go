@@ -0,0 +1,45 @@
+// hint.go
+//
+// Asking the solver for a single next move rather than a full solution.  The square monitors and the round looper run exactly as they
+// do for a solve, but the round looper stops at the end of the first phase in which any square that was not given is finalized.
+package main
+
+import "sort"
+
+// NextHint reports the next square the solver can fill in for the puzzle g, with 0 for an unknown square, along with the value it takes
+// and the name of the technique that placed it.  When more than one square is finalized in the same phase, the first in row-major order
+// is reported.  ok is false when the implemented techniques cannot finalize any more squares, or the puzzle has no solution.
+func NextHint(g [9][9]int) (r, c, value int, technique string, ok bool) {
+	solve(g, solveOptions{stopAtFirstSolved: true})
+	if noSolution.Load() {
+		return
+	}
+	for i := 0; i < 9; i++ {
+		for j := 0; j < 9; j++ {
+			if g[i][j] == 0 && board[i][j].isFinal {
+				value = board[i][j].possVal.Values()[0]
+				return i, j, value, string(board[i][j].solvedBy), true
+			}
+		}
+	}
+	return
+}
+
+// UnsolvedCell is a square that has not been finalized, and the number of values still possible for it.
+type UnsolvedCell struct {
+	R, C, Count int
+}
+
+// UnsolvedCells returns the squares of the board from the last solve, or hint, that have not been finalized, ordered from the fewest
+// possible values to the most, and by row and then column among squares with the same count.  It must not be called while a solve runs.
+func UnsolvedCells() (cells []UnsolvedCell) {
+	for i := 0; i < 9; i++ {
+		for j := 0; j < 9; j++ {
+			if !board[i][j].isFinal {
+				cells = append(cells, UnsolvedCell{i, j, board[i][j].possVal.Count()})
+			}
+		}
+	}
+	sort.SliceStable(cells, func(a, b int) bool { return cells[a].Count < cells[b].Count })
+	return
+}
@@ -0,0 +1,203 @@
+// history.go
+//
+// A record of every change the square monitors make to the board during a solve, so that once the solve has finished the board can be
+// stepped backward and forward through it one deduction at a time.  Each move holds the state of the one square it changed, before and
+// after.  Moves on different squares within the same phase of a round are independent of each other, so the order in which the square
+// monitors happen to record them does not matter; moves on the same square are always recorded in order by the monitor that owns it.
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+)
+
+type move struct {
+	r, c     int
+	before   squareVal
+	after    squareVal
+	reason   technique // the technique behind the set or clear message that made the change
+	solvedBy technique // how the square came to be finalized, if the move finalized it
+	round    int       // the round the move was made in, or 0 while the board was being set up
+	phase    int       // the phase of the solve the move was made in, counting each batch of messages forwarded by the round looper
+}
+
+var history []move
+var historyPos int // history[:historyPos] is applied to the board, history[historyPos:] has been undone
+var historyMu sync.Mutex
+
+func resetHistory() {
+	historyMu.Lock()
+	history = history[:0]
+	historyPos = 0
+	historyMu.Unlock()
+}
+
+// recordMove is called by the square monitor that owns square r, c, once it has changed the square from before to its current state.
+func recordMove(r, c int, before squareVal, reason technique) {
+	sqr := &board[r][c]
+	historyMu.Lock()
+	history = append(history, move{r, c, before, sqr.possVal, reason, sqr.solvedBy, roundsRun, phasesRun})
+	historyPos = len(history)
+	historyMu.Unlock()
+}
+
+// Undo reverts the most recent deduction of the last solve that is still applied to the board, and reports which square it restored
+// and the technique behind the deduction.  ok is false when there is nothing left to undo.  It must not be called while a solve runs.
+func Undo() (r, c int, reason string, ok bool) {
+	historyMu.Lock()
+	defer historyMu.Unlock()
+	if historyPos == 0 {
+		return
+	}
+	historyPos--
+	m := history[historyPos]
+	sqr := &board[m.r][m.c]
+	sqr.possVal = m.before
+	sqr.isFinal = m.before.IsSingle()
+	if !sqr.isFinal {
+		sqr.solvedBy = ""
+	}
+	return m.r, m.c, string(m.reason), true
+}
+
+// Redo reapplies the deduction most recently reverted by Undo.  ok is false when there is nothing to redo.
+func Redo() (r, c int, reason string, ok bool) {
+	historyMu.Lock()
+	defer historyMu.Unlock()
+	if historyPos == len(history) {
+		return
+	}
+	m := history[historyPos]
+	historyPos++
+	sqr := &board[m.r][m.c]
+	sqr.possVal = m.after
+	sqr.isFinal = m.after.IsSingle()
+	sqr.solvedBy = m.solvedBy
+	return m.r, m.c, string(m.reason), true
+}
+
+// finalizedRounds returns the round of the last solve in which each square was finalized, from its history, with -1 for a given or a
+// square that was never finalized.  A square finalized while the givens were being set up has round 0.
+func finalizedRounds() (rounds [9][9]int) {
+	for i := 0; i < 9; i++ {
+		for j := 0; j < 9; j++ {
+			rounds[i][j] = -1
+		}
+	}
+	historyMu.Lock()
+	defer historyMu.Unlock()
+	for _, m := range history[:historyPos] {
+		if m.after.IsSingle() && !m.before.IsSingle() {
+			rounds[m.r][m.c] = m.round
+		}
+	}
+	return
+}
+
+// writeOrder writes rounds to w as nine lines of nine numbers, each the round its square was finalized in, with - for a given and . for
+// a square that was never finalized, so that the way the solve spread out from the givens shows at a glance.
+func writeOrder(w io.Writer, rounds [9][9]int, grid [9][9]int) {
+	fmt.Fprintln(w)
+	for i := 0; i < 9; i++ {
+		var line strings.Builder
+		for j := 0; j < 9; j++ {
+			if j > 0 && j%blockW == 0 {
+				line.WriteString("  ")
+			}
+			switch {
+			case grid[i][j] != 0:
+				fmt.Fprintf(&line, "%3s", "-")
+			case rounds[i][j] < 0:
+				fmt.Fprintf(&line, "%3s", ".")
+			default:
+				fmt.Fprintf(&line, "%3d", rounds[i][j])
+			}
+		}
+		fmt.Fprintln(w, line.String())
+	}
+}
+
+// movesInOrder returns a copy of the history of the last solve.  Moves on different squares in the same phase are recorded in whatever
+// order the square monitors made them, so they are put in order of phase and then square.  The sort is stable, so the moves on each
+// square stay in the order they were made.
+func movesInOrder() []move {
+	historyMu.Lock()
+	moves := append([]move(nil), history...)
+	historyMu.Unlock()
+	sort.SliceStable(moves, func(a, b int) bool {
+		ma, mb := moves[a], moves[b]
+		if ma.phase != mb.phase {
+			return ma.phase < mb.phase
+		}
+		if ma.r != mb.r {
+			return ma.r < mb.r
+		}
+		return ma.c < mb.c
+	})
+	return moves
+}
+
+// eliminationCounts returns the number of possible values each technique cleared in the last solve, from its history.  The values a
+// set ruled out count for the technique that made it, and clearing the peers of a finalized square counts as solved-peer.
+func eliminationCounts() map[technique]int {
+	counts := map[technique]int{}
+	historyMu.Lock()
+	defer historyMu.Unlock()
+	for _, m := range history[:historyPos] {
+		counts[m.reason] += m.before.Remove(m.after).Count()
+	}
+	return counts
+}
+
+// pathLength returns how many deductions the last solve made: the moves that set a square or cleared values from it, other than the
+// givens, any state the solve was resumed from, and the clears a finalized square makes in the squares it sees, which follow from it
+// without any thought.  These are the steps of the -explain-html walkthrough.
+func pathLength() (n int) {
+	historyMu.Lock()
+	defer historyMu.Unlock()
+	for _, m := range history[:historyPos] {
+		if m.reason != given && m.reason != savedState && m.reason != solvedPeer {
+			n++
+		}
+	}
+	return
+}
+
+// SolvePathLength solves the puzzle g, with 0 for an unknown square, and returns how many deductions the solve took, as a rough measure
+// of how much work it is for a person.  A move that clears several values from a square counts once.  It returns -1 if the puzzle cannot
+// be solved with the techniques that are turned on, or has no solution.  Only one solve can run at a time.
+func SolvePathLength(g [9][9]int) int {
+	solve(g, solveOptions{})
+	if noSolution.Load() || stalled {
+		return -1
+	}
+	return pathLength()
+}
+
+// writeStats writes counts to w as one line, such as "Candidates eliminated: solved-peer 212, hidden-single 31, pointing 9", the most
+// first and by name among equal counts.
+func writeStats(w io.Writer, counts map[technique]int) {
+	var names []technique
+	for t, n := range counts {
+		if n > 0 {
+			names = append(names, t)
+		}
+	}
+	sort.Slice(names, func(a, b int) bool {
+		if counts[names[a]] != counts[names[b]] {
+			return counts[names[a]] > counts[names[b]]
+		}
+		return names[a] < names[b]
+	})
+	parts := make([]string, len(names))
+	for k, t := range names {
+		parts[k] = fmt.Sprintf("%s %d", t, counts[t])
+	}
+	if len(parts) == 0 {
+		parts = []string{"none"}
+	}
+	fmt.Fprintf(w, "Candidates eliminated: %s\n", strings.Join(parts, ", "))
+}
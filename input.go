@@ -0,0 +1,428 @@
+// input.go
+//
+// Reading the initial state of a puzzle.  Each of the supported file layouts is parsed into a 9x9 grid of ints, with 0 for a square
+// that has no initial value, and captureBoard then seeds the square monitors from that grid.
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+func setSymbols(syms, blankSym string) error {
+	newSymbols := []rune(syms)
+	newBlank := []rune(blankSym)
+	if len(newSymbols) != 9 {
+		return fmt.Errorf("Symbol table %q must have exactly nine characters", syms)
+	}
+	if len(newBlank) != 1 {
+		return fmt.Errorf("Blank symbol %q must be a single character", blankSym)
+	}
+	if reservedSymbol(newBlank[0]) {
+		return fmt.Errorf("Blank symbol %q is reserved", blankSym)
+	}
+	seen := map[rune]bool{newBlank[0]: true}
+	for _, sym := range newSymbols {
+		if seen[sym] || reservedSymbol(sym) {
+			return fmt.Errorf("Symbol %q is repeated or reserved", sym)
+		}
+		seen[sym] = true
+	}
+	symbols = newSymbols
+	blankSymbol = newBlank[0]
+	return nil
+}
+
+// reservedSymbol reports whether r has a meaning of its own in a puzzle file, and so can stand for neither a value nor a blank: , and ;
+// separate the fields of the CSV and semicolon layouts, and # starts a comment.
+func reservedSymbol(r rune) bool {
+	return r == ',' || r == ';' || r == '#'
+}
+
+func symbolToInt() map[rune]int {
+	symToInt := map[rune]int{blankSymbol: 0}
+	for k, sym := range symbols {
+		symToInt[sym] = k + 1
+	}
+	return symToInt
+}
+
+// puzzleInfo is what a puzzle file can say about the puzzle besides its grid.  Only the JSON layout carries any of it.
+type puzzleInfo struct {
+	Name       string
+	Difficulty string
+	Source     string
+	Cages      []cage     // for a Killer Sudoku
+	Regions    *[9][9]int // for a Jigsaw Sudoku, the block of each square
+}
+
+func readBoard(inFileName string) (grid [9][9]int, info puzzleInfo, err error) {
+	// A directory opens without complaint, and only fails later with a read error that does not say what is wrong.
+	if fi, err := os.Stat(inFileName); err == nil && fi.IsDir() {
+		return grid, info, fmt.Errorf("expected a file, got a directory: %s", inFileName)
+	}
+	inFile, err := os.Open(inFileName)
+	if err != nil {
+		return grid, info, fmt.Errorf("Unable to open file %s: %v", inFileName, err)
+	}
+	defer inFile.Close()
+
+	raw, err := io.ReadAll(inFile)
+	if err != nil {
+		return grid, info, fmt.Errorf("Error reading file %s: %v", inFileName, err)
+	}
+	// A JSON puzzle is known by its content rather than its name, and must be looked for before comments are stripped, since a # in
+	// one of its strings is not a comment.
+	if trimmed := bytes.TrimSpace(raw); len(trimmed) > 0 && trimmed[0] == '{' {
+		grid, info, err = readJSONBoard(trimmed)
+		if err != nil {
+			return grid, info, fmt.Errorf("Error reading file %s: %w", inFileName, err)
+		}
+		return grid, info, nil
+	}
+	data, err := stripComments(bytes.NewReader(raw))
+	if err != nil {
+		return grid, info, fmt.Errorf("Error reading file %s: %v", inFileName, err)
+	}
+	switch strings.ToLower(filepath.Ext(inFileName)) {
+	case ".csv":
+		grid, err = readCSVBoard(data)
+	case ".ss":
+		grid, err = readSSBoard(data)
+	default:
+		grid, err = readSemicolonBoard(data)
+	}
+	if err != nil {
+		return grid, info, fmt.Errorf("Error reading file %s: %w", inFileName, err)
+	}
+	return grid, info, nil
+}
+
+// captureBoard sends each square its given, and if the solve carries on from a saved state, the values that state had ruled out, ahead of
+// the pause that ends the square's part in setting up the board.
+func captureBoard(grid [9][9]int, start *[9][9]squareVal) {
+	intToVal := [...]squareVal{blank, one, two, three, four, five, six, seven, eight, nine}
+	for i := 0; i < 9; i++ {
+		for j := 0; j < 9; j++ {
+			board[i][j].inChan <- updateMsg{intToVal[grid[i][j]], set, i, j, given}
+			if start != nil && grid[i][j] == 0 {
+				board[i][j].inChan <- updateMsg{blank &^ start[i][j], clear, i, j, savedState}
+			}
+			board[i][j].inChan <- updateMsg{action: pause}
+		}
+	}
+}
+
+// scanLines is a bufio.SplitFunc like bufio.ScanLines, which also ends a line at a lone \r, as in a file saved on an old Mac.  ScanLines
+// only drops the \r of a Windows \r\n, and would read a whole file of lone \r as one line.
+func scanLines(data []byte, atEOF bool) (advance int, token []byte, err error) {
+	if atEOF && len(data) == 0 {
+		return 0, nil, nil
+	}
+	if i := bytes.IndexAny(data, "\r\n"); i >= 0 {
+		switch {
+		case data[i] == '\n':
+			return i + 1, data[:i], nil
+		case i+1 < len(data) && data[i+1] == '\n':
+			return i + 2, data[:i], nil
+		case i+1 < len(data) || atEOF:
+			return i + 1, data[:i], nil
+		}
+		// A \r at the end of what has been read so far may be the start of a \r\n.
+		return 0, nil, nil
+	}
+	if atEOF {
+		return len(data), data, nil
+	}
+	return 0, nil, nil
+}
+
+// stripComments removes everything from a # to the end of its line, and then any lines left blank, so that a puzzle file can describe
+// itself ahead of, or alongside, the rows of the grid.
+func stripComments(r io.Reader) (io.Reader, error) {
+	var b strings.Builder
+	scanner := bufio.NewScanner(r)
+	scanner.Split(scanLines)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if k := strings.IndexByte(line, '#'); k >= 0 {
+			line = line[:k]
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		b.WriteString(line)
+		b.WriteByte('\n')
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return strings.NewReader(b.String()), nil
+}
+
+func readSemicolonBoard(r io.Reader) (grid [9][9]int, err error) {
+	// Nine lines of the form 1,2,3;4,5,6;7,8,9; with the blank symbol for a square that has no initial value.
+	symToInt := symbolToInt()
+	for i := 0; i < 9; i++ {
+		var iv [9]rune
+
+		n, err := fmt.Fscanf(r, "%c,%c,%c;%c,%c,%c;%c,%c,%c;\n", &iv[0], &iv[1], &iv[2], &iv[3], &iv[4], &iv[5], &iv[6], &iv[7], &iv[8])
+		if err != nil {
+			return grid, inputErrorf(i, -1, "Invalid input line %d: %v", i, err)
+		}
+		if n != 9 {
+			return grid, inputErrorf(i, -1, "Insufficient input line %d", i)
+		}
+		for j := 0; j < 9; j++ {
+			v, ok := symToInt[iv[j]]
+			if !ok {
+				return grid, inputErrorf(i, j, "Invalid input line %d, position %d", i, j)
+			}
+			grid[i][j] = v
+		}
+	}
+	return grid, nil
+}
+
+func readCSVBoard(r io.Reader) (grid [9][9]int, err error) {
+	// Nine records of nine fields each, as exported by a spreadsheet.  An empty field, or a 0 unless -symbols makes it a value, is a
+	// square with no initial value.
+	symToInt := symbolToInt()
+	if _, ok := symToInt['0']; !ok {
+		symToInt['0'] = 0
+	}
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = 9
+	cr.TrimLeadingSpace = true
+	for i := 0; i < 9; i++ {
+		record, err := cr.Read()
+		if err != nil {
+			return grid, inputErrorf(i, -1, "%v", err)
+		}
+		for j, field := range record {
+			field = strings.TrimSpace(field)
+			if field == "" {
+				continue
+			}
+			f := []rune(field)
+			v, ok := symToInt[f[0]]
+			if len(f) != 1 || !ok {
+				return grid, inputErrorf(i, j, "Invalid input line %d, position %d", i, j)
+			}
+			grid[i][j] = v
+		}
+	}
+	return grid, nil
+}
+
+func readSSBoard(r io.Reader) (grid [9][9]int, err error) {
+	// The Simple Sudoku layout: nine lines of nine symbols, with . for a square that has no initial value.  The blocks are usually
+	// marked off with | within a line and with lines of - and +, and the grid may be framed with * at the corners; all of these are
+	// skipped.
+	symToInt := symbolToInt()
+	if _, ok := symToInt['.']; !ok {
+		symToInt['.'] = 0
+	}
+	scanner := bufio.NewScanner(r)
+	scanner.Split(scanLines)
+	i := 0
+	for scanner.Scan() {
+		line := strings.Map(func(c rune) rune {
+			if c == '|' || c == ' ' || c == '\t' {
+				return -1
+			}
+			return c
+		}, scanner.Text())
+		if strings.Trim(line, "-+*") == "" {
+			continue
+		}
+		if i == 9 {
+			return grid, inputErrorf(-1, -1, "Too many input lines")
+		}
+		row := []rune(line)
+		if len(row) != 9 {
+			return grid, inputErrorf(i, -1, "Invalid input line %d, expected 9 squares, got %d", i, len(row))
+		}
+		for j, sym := range row {
+			v, ok := symToInt[sym]
+			if !ok {
+				return grid, inputErrorf(i, j, "Invalid input line %d, position %d", i, j)
+			}
+			grid[i][j] = v
+		}
+		i++
+	}
+	if err := scanner.Err(); err != nil {
+		return grid, err
+	}
+	if i < 9 {
+		return grid, inputErrorf(i, -1, "Insufficient input, %d lines", i)
+	}
+	return grid, nil
+}
+
+// parseGridString parses a puzzle written on one line as the 81 squares in row order, with the blank symbol, . or 0 for a square that has
+// no initial value.
+func parseGridString(line string) (grid [9][9]int, err error) {
+	symToInt := symbolToInt()
+	for _, sym := range ".0" {
+		if _, ok := symToInt[sym]; !ok {
+			symToInt[sym] = 0
+		}
+	}
+	squares := []rune(line)
+	if len(squares) != 81 {
+		return grid, inputErrorf(-1, -1, "expected 81 squares, got %d", len(squares))
+	}
+	for k, sym := range squares {
+		v, ok := symToInt[sym]
+		if !ok {
+			return [9][9]int{}, inputErrorf(k/9, k%9, "invalid symbol %q at row %d, column %d", sym, k/9+1, k%9+1)
+		}
+		grid[k/9][k%9] = v
+	}
+	return grid, nil
+}
+
+// parseGridRows parses a puzzle given as nine rows, each written as its nine squares as parseGridString takes them, such as 53..7....
+func parseGridRows(rows []string) (grid [9][9]int, err error) {
+	if len(rows) != 9 {
+		return grid, inputErrorf(-1, -1, "expected 9 rows, got %d", len(rows))
+	}
+	for i, row := range rows {
+		if n := len([]rune(row)); n != 9 {
+			return grid, inputErrorf(i, -1, "row %d has %d squares, expected 9", i+1, n)
+		}
+	}
+	return parseGridString(strings.Join(rows, ""))
+}
+
+// readBatch reads a file of many puzzles.  Each is either one line, written as parseGridString takes it, or nine lines in the semicolon
+// layout, which may be separated from the next puzzle by blank lines.  A puzzle that cannot be read is returned as an error in errs, at
+// the same index as its zero grid in grids, so that the caller can report it and go on with the rest.
+func readBatch(inFileName string) (grids [][9][9]int, errs []error, err error) {
+	if info, err := os.Stat(inFileName); err == nil && info.IsDir() {
+		return nil, nil, fmt.Errorf("expected a file, got a directory: %s", inFileName)
+	}
+	inFile, err := os.Open(inFileName)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Unable to open file %s: %v", inFileName, err)
+	}
+	defer inFile.Close()
+
+	add := func(grid [9][9]int, puzzleErr error) {
+		if puzzleErr != nil {
+			puzzleErr = fmt.Errorf("puzzle %d: %w", len(grids)+1, puzzleErr)
+		}
+		grids = append(grids, grid)
+		errs = append(errs, puzzleErr)
+	}
+	// The lines of a puzzle in the semicolon layout read so far.  Unlike stripComments, the blank lines are kept track of here, since
+	// one ends a puzzle that is short of its nine lines.
+	var group []string
+	endGroup := func() {
+		switch {
+		case len(group) == 0:
+			return
+		case len(group) < 9:
+			add([9][9]int{}, inputErrorf(-1, -1, "expected 9 lines in the semicolon layout, got %d", len(group)))
+		default:
+			add(readSemicolonBoard(strings.NewReader(strings.Join(group, "\n") + "\n")))
+		}
+		group = nil
+	}
+	scanner := bufio.NewScanner(inFile)
+	scanner.Split(scanLines)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if k := strings.IndexByte(line, '#'); k >= 0 {
+			line = line[:k]
+		}
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "":
+			endGroup()
+		case strings.Contains(line, ";"):
+			group = append(group, line)
+			if len(group) == 9 {
+				endGroup()
+			}
+		default:
+			endGroup()
+			add(parseGridString(line))
+		}
+	}
+	endGroup()
+	if err := scanner.Err(); err != nil {
+		return nil, nil, fmt.Errorf("Error reading file %s: %v", inFileName, err)
+	}
+	return grids, errs, nil
+}
+
+// jsonCage is a cage of a Killer Sudoku as it is written in a JSON puzzle file, with each cell given as its row and column counting from 1.
+type jsonCage struct {
+	Sum   int      `json:"sum"`
+	Cells [][2]int `json:"cells"`
+}
+
+func readJSONBoard(raw []byte) (grid [9][9]int, info puzzleInfo, err error) {
+	// An object with the grid either as a string of the 81 squares in row order, with the blank symbol, . or 0 for a square that has
+	// no initial value, or as an array of nine rows of nine numbers, with 0 for a square that has no initial value.  The name,
+	// difficulty, source, the cages of a Killer Sudoku and the regions of a Jigsaw Sudoku are optional.
+	var puzzle struct {
+		Grid       json.RawMessage `json:"grid"`
+		Name       string          `json:"name"`
+		Difficulty string          `json:"difficulty"`
+		Source     string          `json:"source"`
+		Cages      []jsonCage      `json:"cages"`
+		Regions    [][]int         `json:"regions"`
+	}
+	if err := json.Unmarshal(raw, &puzzle); err != nil {
+		return grid, info, inputErrorf(-1, -1, "%v", err)
+	}
+	info = puzzleInfo{Name: puzzle.Name, Difficulty: puzzle.Difficulty, Source: puzzle.Source}
+	for _, jc := range puzzle.Cages {
+		cg, err := newCage(jc.Sum, jc.Cells, info.Cages)
+		if err != nil {
+			return grid, info, err
+		}
+		info.Cages = append(info.Cages, cg)
+	}
+	if puzzle.Regions != nil {
+		if info.Regions, err = newRegions(puzzle.Regions); err != nil {
+			return grid, info, err
+		}
+	}
+	var gridString string
+	var gridRows [][]int
+	if err := json.Unmarshal(puzzle.Grid, &gridString); err == nil {
+		grid, err = parseGridString(gridString)
+		return grid, info, err
+	}
+	if err := json.Unmarshal(puzzle.Grid, &gridRows); err != nil {
+		return grid, info, inputErrorf(-1, -1, "the grid must be a string or an array of rows")
+	}
+	if len(gridRows) != 9 {
+		return grid, info, inputErrorf(-1, -1, "the grid has %d rows, expected 9", len(gridRows))
+	}
+	for i, gridRow := range gridRows {
+		if len(gridRow) != 9 {
+			return grid, info, inputErrorf(i, -1, "Invalid input line %d, expected 9 squares, got %d", i, len(gridRow))
+		}
+		for j, v := range gridRow {
+			if v < 0 || v > 9 {
+				return grid, info, inputErrorf(i, j, "Invalid input line %d, position %d", i, j)
+			}
+			grid[i][j] = v
+		}
+	}
+	return grid, info, nil
+}
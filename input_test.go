@@ -0,0 +1,217 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestSSRoundTrip(t *testing.T) {
+	puzzle, _, err := readBoard("FriDec4-2020")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		name string
+		grid [9][9]int
+	}{
+		{"a puzzle", puzzle},
+		{"a solution", AllSolutions(puzzle, 1)[0]},
+		{"an empty grid", [9][9]int{}},
+	} {
+		var buf bytes.Buffer
+		writeSSBoard(&buf, tc.grid)
+		written := buf.String()
+		// The written layout marks off the blocks with | and lines of - and +, framed by lines with * at the corners.
+		lines := strings.Split(strings.TrimSuffix(written, "\n"), "\n")
+		if len(lines) != 13 || lines[0] != "*-----------*" || lines[4] != "|---+---+---|" || lines[12] != "*-----------*" {
+			t.Errorf("%s: written as\n%s", tc.name, written)
+		}
+		grid, err := readSSBoard(strings.NewReader(written))
+		if err != nil {
+			t.Errorf("%s: reading back what was written: %v", tc.name, err)
+		} else if grid != tc.grid {
+			t.Errorf("%s: reading back what was written gives a different grid", tc.name)
+		}
+	}
+}
+
+func TestReadSSBoardLayouts(t *testing.T) {
+	want, err := readSSBoard(strings.NewReader(".8....013\n...2.9..6\n....1....\n.93..7.8.\n4.8...2.7\n.7.1..53.\n....9....\n1..4.2...\n86.....7.\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		name, text string
+	}{
+		{"separators and a * frame", "*-----------*\n|.8.|...|.13|\n|...|2.9|..6|\n|...|.1.|...|\n|---+---+---|\n|.93|..7|.8.|\n" +
+			"|4.8|...|2.7|\n|.7.|1..|53.|\n|---+---+---|\n|...|.9.|...|\n|1..|4.2|...|\n|86.|...|.7.|\n*-----------*\n"},
+		{"separators with no frame", ".8.|...|.13\n...|2.9|..6\n...|.1.|...\n---+---+---\n.93|..7|.8.\n4.8|...|2.7\n.7.|1..|53.\n" +
+			"---+---+---\n...|.9.|...\n1..|4.2|...\n86.|...|.7.\n"},
+		{"spaces and Windows line ends", " . 8 . | . . . | . 1 3\r\n . . . | 2 . 9 | . . 6\r\n . . . | . 1 . | . . .\r\n" +
+			"-------+-------+------\r\n . 9 3 | . . 7 | . 8 .\r\n 4 . 8 | . . . | 2 . 7\r\n . 7 . | 1 . . | 5 3 .\r\n" +
+			"-------+-------+------\r\n . . . | . 9 . | . . .\r\n 1 . . | 4 . 2 | . . .\r\n 8 6 . | . . . | . 7 .\r\n"},
+	} {
+		grid, err := readSSBoard(strings.NewReader(tc.text))
+		if err != nil {
+			t.Errorf("%s: %v", tc.name, err)
+		} else if grid != want {
+			t.Errorf("%s: read a different grid", tc.name)
+		}
+		// Writing what was read and reading it again gives the same grid.
+		var buf bytes.Buffer
+		writeSSBoard(&buf, grid)
+		if again, err := readSSBoard(&buf); err != nil || again != want {
+			t.Errorf("%s: the grid does not survive being written and read again: %v", tc.name, err)
+		}
+	}
+}
+
+func TestReadCSVBoardZeroSymbol(t *testing.T) {
+	defer setSymbols("123456789", "0")
+	row := "1,,0,2,3,4,5,6,7\n"
+	csv := strings.Repeat(row, 9)
+
+	grid, err := readCSVBoard(strings.NewReader(csv))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if grid[0][0] != 1 || grid[0][1] != 0 || grid[0][2] != 0 || grid[0][3] != 2 {
+		t.Errorf("with the usual symbols, row 1 reads as %v", grid[0])
+	}
+
+	// With 0 as one of the symbols, it is a value, and only an empty field or the blank symbol is unknown.
+	if err := setSymbols("012345678", "."); err != nil {
+		t.Fatal(err)
+	}
+	grid, err = readCSVBoard(strings.NewReader(csv))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if grid[0][0] != 2 || grid[0][1] != 0 || grid[0][2] != 1 || grid[0][3] != 3 {
+		t.Errorf("with 0 as the symbol for 1, row 1 reads as %v", grid[0])
+	}
+}
+
+func TestSetSymbolsReserved(t *testing.T) {
+	defer setSymbols("123456789", "0")
+	for _, tc := range []struct {
+		syms, blank string
+		ok          bool
+	}{
+		{"123456789", "0", true},
+		{"ABCDEFGHI", ".", true},
+		{"123456789", "#", false},
+		{"123456789", ",", false},
+		{"123456789", ";", false},
+		{"12345678#", "0", false},
+		{"1234,6789", "0", false},
+		{"123456789", "5", false},
+		{"123456788", "0", false},
+	} {
+		if err := setSymbols(tc.syms, tc.blank); (err == nil) != tc.ok {
+			t.Errorf("-symbols %q -blank %q: %v", tc.syms, tc.blank, err)
+		}
+	}
+	// A rejected table leaves the symbols as they were.
+	setSymbols("123456789", "0")
+	setSymbols("123456789", "#")
+	if string(symbols) != "123456789" || blankSymbol != '0' {
+		t.Errorf("a rejected blank symbol changed the symbols to %q and %q", string(symbols), blankSymbol)
+	}
+}
+
+// TestLineEnds reads a puzzle written in each layout with Unix, Windows and old Mac line ends, and checks each reads the same.  The
+// readers that take an io.Reader are also given it a byte at a time, so that a \r\n is split between reads.
+func TestLineEnds(t *testing.T) {
+	puzzle, _, err := readBoard("FriDec4-2020")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var line string
+	var semicolon, csvText strings.Builder
+	for i := 0; i < 9; i++ {
+		var sq [9]string
+		for j := 0; j < 9; j++ {
+			sq[j] = string(rune('0' + puzzle[i][j]))
+		}
+		line += strings.Join(sq[:], "")
+		fmt.Fprintf(&semicolon, "%s,%s,%s;%s,%s,%s;%s,%s,%s;\n", sq[0], sq[1], sq[2], sq[3], sq[4], sq[5], sq[6], sq[7], sq[8])
+		fmt.Fprintln(&csvText, strings.Join(sq[:], ","))
+	}
+	var ss bytes.Buffer
+	writeSSBoard(&ss, puzzle)
+	// A saved state is written from the board, here a solve of the puzzle stopped after its first round.
+	solve(puzzle, solveOptions{atRound: 1, out: io.Discard})
+	wantState := boardState()
+	var state bytes.Buffer
+	if err := SaveState(&state); err != nil {
+		t.Fatal(err)
+	}
+	layouts := []struct {
+		name, text string
+	}{
+		{"puzzle", "# The semicolon layout\n" + semicolon.String()},
+		{"puzzle.csv", "# A CSV file\n" + csvText.String()},
+		{"puzzle.ss", "# The Simple Sudoku layout\n" + ss.String()},
+		{"puzzle.json", "{\n  \"name\": \"FriDec4-2020\",\n  \"grid\": \"" + line + "\"\n}\n"},
+	}
+	dir := t.TempDir()
+	for _, ends := range []string{"\n", "\r\n", "\r"} {
+		for _, l := range layouts {
+			name := filepath.Join(dir, l.name)
+			if err := os.WriteFile(name, []byte(strings.ReplaceAll(l.text, "\n", ends)), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			if grid, _, err := readBoard(name); err != nil || grid != puzzle {
+				t.Errorf("%s with %q line ends: read %v, %v", l.name, ends, grid, err)
+			}
+		}
+
+		name := filepath.Join(dir, "batch")
+		batch := line + "\n\n" + semicolon.String() + "\n" + line + "\n"
+		if err := os.WriteFile(name, []byte(strings.ReplaceAll(batch, "\n", ends)), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		grids, errs, err := readBatch(name)
+		if err != nil || len(grids) != 3 {
+			t.Errorf("a batch with %q line ends: read %d puzzles, %v", ends, len(grids), err)
+		}
+		for k := range grids {
+			if errs[k] != nil || grids[k] != puzzle {
+				t.Errorf("a batch with %q line ends: puzzle %d read as %v, %v", ends, k+1, grids[k], errs[k])
+			}
+		}
+
+		text := strings.ReplaceAll(semicolon.String(), "\n", ends)
+		stripped, err := stripComments(iotest.OneByteReader(strings.NewReader("# a comment" + ends + text)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if grid, err := readSemicolonBoard(stripped); err != nil || grid != puzzle {
+			t.Errorf("the semicolon layout with %q line ends, a byte at a time: read %v, %v", ends, grid, err)
+		}
+		if grid, err := readSSBoard(iotest.OneByteReader(strings.NewReader(strings.ReplaceAll(ss.String(), "\n", ends)))); err != nil ||
+			grid != puzzle {
+			t.Errorf("the Simple Sudoku layout with %q line ends, a byte at a time: read %v, %v", ends, grid, err)
+		}
+		if got, err := readState(iotest.OneByteReader(strings.NewReader(strings.ReplaceAll(state.String(), "\n", ends)))); err != nil ||
+			got != wantState {
+			t.Errorf("a saved state with %q line ends, a byte at a time: %v", ends, err)
+		}
+	}
+
+	// XWingCR is XWing with lone \r line ends.
+	want, _, err := readBoard("XWing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if grid, _, err := readBoard("XWingCR"); err != nil || grid != want {
+		t.Errorf("XWingCR does not read as XWing: %v", err)
+	}
+}
@@ -0,0 +1,106 @@
+// jigsaw.go
+//
+// Jigsaw Sudoku, in which the nine blocks are irregular shapes rather than 3x3 squares.  Everything that works with blocks looks them up
+// here, in blockOf and blockSquares, so the regions read from a JSON puzzle file take the place of the usual blocks throughout: in the
+// clears sent when a square is finalized, in the block analysis, in the techniques that span the whole grid and in the grid helpers.
+package main
+
+// blockW and blockH are the width and height of the usual blocks.  Only 9x9 grids are supported, with these 3x3 blocks or a jigsaw's nine
+// regions: the grid is nine squares a side throughout the board, the square monitors, the techniques and the puzzle layouts, so 6x6, 8x8
+// and 12x12 grids do not work, and changing these alone does not make them.
+const (
+	blockW = 3
+	blockH = 3
+)
+
+// blockOf[r][c] is the block that square r, c belongs to, and blockSquares[b] lists the squares of block b in row order.  They hold the
+// usual blockW by blockH blocks, numbered across each band of blockH rows in turn, unless the puzzle gives its own regions.
+var blockOf [9][9]int
+var blockSquares [9][9]gridPos
+
+// blockAnchor[b] is the square the analysis of block b is handed to each round, its third in row order: for the usual blocks, row 0, 3
+// or 6 and column 2, 5 or 8.  The analysis message names the block itself, so the square only decides which monitor does the work.
+var blockAnchor [9]gridPos
+
+// jigsaw is set when the blocks are a puzzle's own regions.
+var jigsaw bool
+
+func init() {
+	setBlocks(nil)
+}
+
+// setBlocks makes regions, which give each square a block numbered 0 through 8 and have been checked by newRegions, the blocks, or
+// goes back to the usual blocks if regions is nil.
+func setBlocks(regions *[9][9]int) {
+	jigsaw = regions != nil
+	var count [9]int
+	for i := 0; i < 9; i++ {
+		for j := 0; j < 9; j++ {
+			b := i/blockH*(9/blockW) + j/blockW
+			if jigsaw {
+				b = regions[i][j]
+			}
+			blockOf[i][j] = b
+			blockSquares[b][count[b]] = gridPos{i, j}
+			count[b]++
+		}
+	}
+	for b := range blockAnchor {
+		blockAnchor[b] = blockSquares[b][2]
+	}
+}
+
+// newRegions checks a region map read from a puzzle file, nine rows of nine region numbers 1 through 9, and returns it numbered from 0.
+// Each region must have nine squares, all joined to each other through squares of the region side by side or one above the other.
+func newRegions(rows [][]int) (regions *[9][9]int, err error) {
+	if len(rows) != 9 {
+		return nil, inputErrorf(-1, -1, "the regions have %d rows, expected 9", len(rows))
+	}
+	regions = new([9][9]int)
+	var count [9]int
+	for i, row := range rows {
+		if len(row) != 9 {
+			return nil, inputErrorf(i, -1, "regions row %d has %d squares, expected 9", i+1, len(row))
+		}
+		for j, id := range row {
+			if id < 1 || id > 9 {
+				return nil, inputErrorf(i, j, "the region at row %d, column %d must be 1 to 9, not %d", i+1, j+1, id)
+			}
+			regions[i][j] = id - 1
+			count[id-1]++
+		}
+	}
+	for b, n := range count {
+		if n != 9 {
+			return nil, inputErrorf(-1, -1, "region %d has %d squares, expected 9", b+1, n)
+		}
+	}
+	// Spread out from the first square of each region found, and check that it reaches all nine.
+	var reached [9][9]bool
+	for i := 0; i < 9; i++ {
+		for j := 0; j < 9; j++ {
+			if reached[i][j] {
+				continue
+			}
+			b := regions[i][j]
+			n := 0
+			stack := []gridPos{{i, j}}
+			reached[i][j] = true
+			for len(stack) > 0 {
+				p := stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+				n++
+				for _, q := range []gridPos{{p.r - 1, p.c}, {p.r + 1, p.c}, {p.r, p.c - 1}, {p.r, p.c + 1}} {
+					if q.r >= 0 && q.r < 9 && q.c >= 0 && q.c < 9 && !reached[q.r][q.c] && regions[q.r][q.c] == b {
+						reached[q.r][q.c] = true
+						stack = append(stack, q)
+					}
+				}
+			}
+			if n != 9 {
+				return nil, inputErrorf(i, j, "region %d is not all in one piece", b+1)
+			}
+		}
+	}
+	return regions, nil
+}
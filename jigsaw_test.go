@@ -0,0 +1,37 @@
+package main
+
+import "testing"
+
+func TestBlockAnchorsInTheirBlocks(t *testing.T) {
+	_, info, err := readBoard("Jigsaw.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Regions == nil {
+		t.Fatalf("Jigsaw.json has no regions")
+	}
+	defer setBlocks(nil)
+	for _, layout := range []struct {
+		name    string
+		regions *[9][9]int
+	}{{"usual", nil}, {"jigsaw", info.Regions}} {
+		setBlocks(layout.regions)
+		for b, p := range blockAnchor {
+			if blockOf[p.r][p.c] != b {
+				t.Errorf("%s blocks: the anchor of block %d, R%dC%d, is in block %d", layout.name, b+1, p.r+1, p.c+1, blockOf[p.r][p.c]+1)
+			}
+		}
+		_, _, blocks, _ := analysedUnits(analysisMsgs())
+		for b, n := range blocks {
+			if n != 1 {
+				t.Errorf("%s blocks: block %d is analysed %d times a round", layout.name, b+1, n)
+			}
+		}
+		for _, msg := range analysisMsgs() {
+			if msg.action == analyseBlock && blockOf[msg.destR][msg.destC] != int(msg.val) {
+				t.Errorf("%s blocks: the analysis of block %d goes to R%dC%d, in block %d", layout.name, msg.val+1, msg.destR+1,
+					msg.destC+1, blockOf[msg.destR][msg.destC]+1)
+			}
+		}
+	}
+}
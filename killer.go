@@ -0,0 +1,107 @@
+// killer.go
+//
+// Killer Sudoku, in which the grid is also divided into cages, each given the sum of its squares, with no value repeated within a cage.
+// The cages come from a JSON puzzle file.  They are looked at with the other techniques that span the whole grid, at the end of each
+// round's analysis phase: a value stays possible for a square of a cage only if the other squares of the cage can still be filled with
+// different values that make up the rest of the sum.
+package main
+
+const (
+	cageSum technique = "cage-sum"
+)
+
+type cage struct {
+	sum     int
+	squares []gridPos
+}
+
+// cages is set from the puzzle file for a Killer Sudoku, and is empty otherwise.
+var cages []cage
+
+// newCage checks that a cage read from a puzzle file is one a Sudoku could have, with cells given as row and column counting from 1,
+// and that none of its squares is already in one of the cages before it.
+func newCage(sum int, cells [][2]int, before []cage) (cg cage, err error) {
+	if len(cells) < 1 || len(cells) > 9 {
+		return cg, inputErrorf(-1, -1, "a cage must have 1 to 9 squares, not %d", len(cells))
+	}
+	cg.sum = sum
+	for _, cell := range cells {
+		r, c := cell[0]-1, cell[1]-1
+		if r < 0 || r > 8 || c < 0 || c > 8 {
+			return cg, inputErrorf(-1, -1, "cage square row %d, column %d is not on the grid", cell[0], cell[1])
+		}
+		if inCage(r, c, before) || inCage(r, c, []cage{cg}) {
+			return cg, inputErrorf(r, c, "row %d, column %d is in more than one cage", r+1, c+1)
+		}
+		cg.squares = append(cg.squares, gridPos{r, c})
+	}
+	// The smallest and largest sums of n different values.
+	n := len(cells)
+	if lo, hi := n*(n+1)/2, n*(19-n)/2; sum < lo || sum > hi {
+		return cg, inputErrorf(-1, -1, "a cage of %d squares cannot add up to %d", n, sum)
+	}
+	return cg, nil
+}
+
+// inCage reports whether the square r, c is in any of the cages cs.
+func inCage(r, c int, cs []cage) bool {
+	for _, cg := range cs {
+		for _, p := range cg.squares {
+			if p.r == r && p.c == c {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func checkCages() {
+	for _, cg := range cages {
+		// Try every way of filling the cage with different values that are still possible for their squares and add up to the sum,
+		// and note which values each square takes in at least one of them.
+		supported := make([]squareVal, len(cg.squares))
+		chosen := make([]squareVal, len(cg.squares))
+		var fill func(k int, used squareVal, total int)
+		fill = func(k int, used squareVal, total int) {
+			if k == len(cg.squares) {
+				if total == cg.sum {
+					for i, v := range chosen {
+						supported[i] |= v
+					}
+				}
+				return
+			}
+			p := cg.squares[k]
+			for n, val := 1, one; n <= 9 && total+n <= cg.sum; n, val = n+1, val<<1 {
+				if board[p.r][p.c].possVal.Has(val) && !used.Has(val) {
+					chosen[k] = val
+					fill(k+1, used|val, total+n)
+				}
+			}
+		}
+		fill(0, 0, 0)
+		for i, p := range cg.squares {
+			clearIfPossible(blank&^supported[i], p.r, p.c, cageSum)
+		}
+	}
+}
+
+// cagesValid reports whether each cage of the completed grid g adds up to its sum without repeating a value.
+func cagesValid(g [9][9]int) bool {
+	for _, cg := range cages {
+		var seen uint16
+		total := 0
+		for _, p := range cg.squares {
+			v := g[p.r][p.c]
+			if v < 1 || v > 9 || seen&(1<<(v-1)) != 0 {
+				return false
+			}
+			seen |= 1 << (v - 1)
+			total += v
+		}
+		if total != cg.sum {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,52 @@
+// latex.go
+//
+// The board as LaTeX source, for solve -format latex, to typeset a puzzle or its solution in a document.  It is a plain tabular, with
+// double rules around the blocks and the givens in bold, so it needs no packages beyond LaTeX itself.
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// latexSpecial maps the characters LaTeX treats specially to the commands that typeset them, for symbols chosen with -symbols.
+var latexSpecial = map[rune]string{'#': `\#`, '$': `\$`, '%': `\%`, '&': `\&`, '_': `\_`, '{': `\{`, '}': `\}`, '~': `\textasciitilde{}`,
+	'^': `\textasciicircum{}`, '\\': `\textbackslash{}`}
+
+// writeBoardLaTeX writes state to w as a tabular, with the value of each finalized square, the givens of grid in bold, and the rest
+// left empty.  title and outcome are written above and below it as comments.  It only draws the usual blocks, not a Jigsaw's regions.
+func writeBoardLaTeX(w io.Writer, grid [9][9]int, state [9][9]squareVal, title, outcome string) {
+	if title != "" {
+		fmt.Fprintf(w, "%% %s\n", title)
+	}
+	spec := strings.Repeat("||"+strings.Repeat("c|", blockW-1)+"c", 9/blockW) + "||"
+	fmt.Fprintf(w, "\\begin{tabular}{%s}\n\\hline\\hline\n", spec)
+	for i := 0; i < 9; i++ {
+		cells := make([]string, 9)
+		for j := 0; j < 9; j++ {
+			if !state[i][j].IsSingle() {
+				continue
+			}
+			sym := symbols[state[i][j].Values()[0]-1]
+			cell, ok := latexSpecial[sym]
+			if !ok {
+				cell = string(sym)
+			}
+			if grid[i][j] != 0 {
+				cell = `\textbf{` + cell + `}`
+			}
+			cells[j] = cell
+		}
+		fmt.Fprintf(w, "%s \\\\\n", strings.Join(cells, " & "))
+		if (i+1)%blockH == 0 {
+			fmt.Fprintf(w, "\\hline\\hline\n")
+		} else {
+			fmt.Fprintf(w, "\\hline\n")
+		}
+	}
+	fmt.Fprintf(w, "\\end{tabular}\n")
+	if outcome != "" {
+		fmt.Fprintf(w, "%% %s\n", outcome)
+	}
+}
@@ -0,0 +1,12 @@
+//go:build !js || !wasm
+
+// main.go
+//
+// The entry point for the command line.  A WebAssembly build has its own, in wasm.go, as there is no command line in a browser.
+package main
+
+import "os"
+
+func main() {
+	os.Exit(runCommand(os.Args[1:]))
+}
@@ -0,0 +1,93 @@
+// minimal.go
+//
+// Finding the fewest of the optional techniques that still solve a puzzle, for rate.  Every set of up to maxExhaustive techniques is
+// tried, smallest first, by solving with the rest turned off just as -disable does.  A puzzle that needs more than that is instead
+// solved with all of them, and then with each one in turn dropped, from the hardest to the easiest, for as long as it still solves.
+package main
+
+// maxExhaustive is the most techniques a set is looked for with by trying every combination.  There are about twenty optional
+// techniques, so the sets of three already take over a thousand solves.
+const maxExhaustive = 3
+
+// MinimalTechniques returns the smallest set of optional techniques, leaving out any already disabled, that the solver can solve g
+// with, in the order of optionalTechniques.  Naked singles and clearing the peers of a finalized square are always used.  exact is
+// false if no set of up to maxExhaustive techniques was enough, and the set was found by dropping techniques one at a time; none of
+// them can then be dropped, but a smaller set may still exist.  ok is false if the puzzle cannot be solved even with all of them, or
+// has no solution.
+func MinimalTechniques(g [9][9]int) (names []string, exact, ok bool) {
+	var avail []technique
+	for _, t := range optionalTechniques {
+		if disabled[t] || (!advanced && isAdvanced(t)) {
+			continue
+		}
+		avail = append(avail, t)
+	}
+	saved := disabled
+	defer func() { disabled = saved }()
+	solvesWith := func(set []technique) bool {
+		disabled = map[technique]bool{}
+		for t := range saved {
+			disabled[t] = true
+		}
+		for _, t := range avail {
+			disabled[t] = true
+		}
+		for _, t := range set {
+			disabled[t] = false
+		}
+		solve(g, solveOptions{})
+		return !noSolution.Load() && !stalled
+	}
+	if !solvesWith(avail) {
+		return nil, false, false
+	}
+
+	var found []technique
+	var choose func(start int, set []technique, n int) bool
+	choose = func(start int, set []technique, n int) bool {
+		if n == 0 {
+			if solvesWith(set) {
+				found = append([]technique(nil), set...)
+				return true
+			}
+			return false
+		}
+		for k := start; k <= len(avail)-n; k++ {
+			if choose(k+1, append(set, avail[k]), n-1) {
+				return true
+			}
+		}
+		return false
+	}
+	exact = true
+	for n := 0; n <= maxExhaustive && n <= len(avail); n++ {
+		if choose(0, nil, n) {
+			break
+		}
+	}
+	if found == nil {
+		exact = false
+		found = append([]technique(nil), avail...)
+		for k := len(found) - 1; k >= 0; k-- {
+			without := append(append([]technique(nil), found[:k]...), found[k+1:]...)
+			if solvesWith(without) {
+				found = without
+			}
+		}
+	}
+	names = make([]string, len(found))
+	for k, t := range found {
+		names[k] = string(t)
+	}
+	return names, exact, true
+}
+
+// isAdvanced reports whether t is one of the techniques only used with -advanced.
+func isAdvanced(t technique) bool {
+	for _, a := range advancedTechniques {
+		if a == t {
+			return true
+		}
+	}
+	return false
+}
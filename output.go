@@ -0,0 +1,156 @@
+// output.go
+//
+// Writing a grid back out in one of the layouts that readBoard understands, chosen by the file extension in the same way, so that a
+// puzzle can be passed between this program and others.
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+func writeBoard(outFileName string, grid [9][9]int, info puzzleInfo) error {
+	outFile, err := os.Create(outFileName)
+	if err != nil {
+		return fmt.Errorf("Unable to create file %s: %v", outFileName, err)
+	}
+	w := bufio.NewWriter(outFile)
+	switch strings.ToLower(filepath.Ext(outFileName)) {
+	case ".csv":
+		writeCSVBoard(w, grid)
+	case ".ss":
+		writeSSBoard(w, grid)
+	case ".json":
+		err = writeJSONBoard(w, grid, info)
+	default:
+		writeSemicolonBoard(w, grid)
+	}
+	if ferr := w.Flush(); err == nil {
+		err = ferr
+	}
+	if cerr := outFile.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return fmt.Errorf("Error writing file %s: %v", outFileName, err)
+	}
+	return nil
+}
+
+// squareSymbol returns the character for the value v in a puzzle file, or blank for a square with no value.
+func squareSymbol(v int, blank rune) rune {
+	if v == 0 {
+		return blank
+	}
+	return symbols[v-1]
+}
+
+func writeSemicolonBoard(w io.Writer, grid [9][9]int) {
+	for i := 0; i < 9; i++ {
+		for j := 0; j < 9; j++ {
+			sep := ','
+			if j%blockW == blockW-1 {
+				sep = ';'
+			}
+			fmt.Fprintf(w, "%c%c", squareSymbol(grid[i][j], blankSymbol), sep)
+		}
+		fmt.Fprintln(w)
+	}
+}
+
+func writeCSVBoard(w io.Writer, grid [9][9]int) {
+	for i := 0; i < 9; i++ {
+		fields := make([]string, 9)
+		for j := 0; j < 9; j++ {
+			if grid[i][j] != 0 {
+				fields[j] = string(squareSymbol(grid[i][j], 0))
+			}
+		}
+		fmt.Fprintln(w, strings.Join(fields, ","))
+	}
+}
+
+func writeSSBoard(w io.Writer, grid [9][9]int) {
+	fmt.Fprintln(w, "*-----------*")
+	for i := 0; i < 9; i++ {
+		if i > 0 && i%blockH == 0 {
+			fmt.Fprintln(w, "|---+---+---|")
+		}
+		fmt.Fprint(w, "|")
+		for j := 0; j < 9; j++ {
+			fmt.Fprintf(w, "%c", squareSymbol(grid[i][j], '.'))
+			if j%blockW == blockW-1 {
+				fmt.Fprint(w, "|")
+			}
+		}
+		fmt.Fprintln(w)
+	}
+	fmt.Fprintln(w, "*-----------*")
+}
+
+// writeJSONBoard writes the grid as a string of the 81 squares in row order, with . for a square that has no initial value, along with
+// the name, difficulty, source, cages and regions if the puzzle has them.
+func writeJSONBoard(w io.Writer, grid [9][9]int, info puzzleInfo) error {
+	var puzzle struct {
+		Grid       string     `json:"grid"`
+		Name       string     `json:"name,omitempty"`
+		Difficulty string     `json:"difficulty,omitempty"`
+		Source     string     `json:"source,omitempty"`
+		Cages      []jsonCage `json:"cages,omitempty"`
+		Regions    [][]int    `json:"regions,omitempty"`
+	}
+	squares := make([]rune, 0, 81)
+	for i := 0; i < 9; i++ {
+		for j := 0; j < 9; j++ {
+			squares = append(squares, squareSymbol(grid[i][j], '.'))
+		}
+	}
+	puzzle.Grid, puzzle.Name, puzzle.Difficulty, puzzle.Source = string(squares), info.Name, info.Difficulty, info.Source
+	for _, cg := range info.Cages {
+		jc := jsonCage{Sum: cg.sum}
+		for _, p := range cg.squares {
+			jc.Cells = append(jc.Cells, [2]int{p.r + 1, p.c + 1})
+		}
+		puzzle.Cages = append(puzzle.Cages, jc)
+	}
+	if info.Regions != nil {
+		for i := 0; i < 9; i++ {
+			row := make([]int, 9)
+			for j := 0; j < 9; j++ {
+				row[j] = info.Regions[i][j] + 1
+			}
+			puzzle.Regions = append(puzzle.Regions, row)
+		}
+	}
+	b, err := json.Marshal(puzzle)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%s\n", b)
+	return err
+}
+
+// writeCandidatesJSON writes the possible values of every square of state to w as one line of JSON, for a program that carries on from
+// where the solver stalled: {"candidates":[[[1,4],[7],...],...]}, nine rows of nine squares, each the list of its values, so that a
+// finalized square has just one.
+func writeCandidatesJSON(w io.Writer, state [9][9]squareVal) error {
+	var stalledState struct {
+		Candidates [9][9][]int `json:"candidates"`
+	}
+	for i := 0; i < 9; i++ {
+		for j := 0; j < 9; j++ {
+			stalledState.Candidates[i][j] = state[i][j].Values()
+		}
+	}
+	b, err := json.Marshal(stalledState)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%s\n", b)
+	return err
+}
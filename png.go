@@ -0,0 +1,108 @@
+// png.go
+//
+// Drawing the board as a PNG image, for solve -format png.  The digits come from a small bitmap font kept here rather than from a font
+// file, so that drawing needs nothing beyond the standard library.  The lines between blocks are drawn heavier, following blockOf, so a
+// Jigsaw puzzle is drawn with its own regions.
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"os"
+)
+
+// digitGlyphs holds 1 through 9 as five by seven bitmaps, a row to a string, with # for a set pixel.
+var digitGlyphs = [9][7]string{
+	{"..#..", ".##..", "..#..", "..#..", "..#..", "..#..", ".###."},
+	{".###.", "#...#", "....#", "...#.", "..#..", ".#...", "#####"},
+	{"#####", "...#.", "..#..", "...#.", "....#", "#...#", ".###."},
+	{"...#.", "..##.", ".#.#.", "#..#.", "#####", "...#.", "...#."},
+	{"#####", "#....", "####.", "....#", "....#", "#...#", ".###."},
+	{"..##.", ".#...", "#....", "####.", "#...#", "#...#", ".###."},
+	{"#####", "....#", "...#.", "..#..", ".#...", ".#...", ".#..."},
+	{".###.", "#...#", "#...#", ".###.", "#...#", "#...#", ".###."},
+	{".###.", "#...#", "#...#", ".####", "....#", "...#.", ".##.."},
+}
+
+const (
+	pngSquare = 44 // the width and height of a square, in pixels
+	pngScale  = 4  // the size of a glyph pixel, in pixels
+	pngMargin = 2  // room around the grid for the outside line
+)
+
+var (
+	pngGivenColour  = color.RGBA{0x00, 0x00, 0x00, 0xff}
+	pngSolvedColour = color.RGBA{0x1f, 0x4e, 0xb4, 0xff}
+	pngLineColour   = color.RGBA{0xa0, 0xa0, 0xa0, 0xff}
+)
+
+// writePNG draws the board as it stands to the file name, with the squares given in grid in black and those the solver finalized in
+// blue.  Squares not yet finalized are left empty.  The digits are drawn whatever symbols are in use.
+func writePNG(name string, grid [9][9]int) error {
+	size := 2*pngMargin + 9*pngSquare
+	img := image.NewRGBA(image.Rect(0, 0, size+1, size+1))
+	draw.Draw(img, img.Bounds(), image.White, image.Point{}, draw.Src)
+	fill := func(x0, y0, x1, y1 int, c color.Color) {
+		draw.Draw(img, image.Rect(x0, y0, x1, y1), image.NewUniform(c), image.Point{}, draw.Src)
+	}
+	current := boardGrid()
+	for i := 0; i < 9; i++ {
+		for j := 0; j < 9; j++ {
+			if current[i][j] == 0 {
+				continue
+			}
+			c := pngSolvedColour
+			if grid[i][j] != 0 {
+				c = pngGivenColour
+			}
+			glyph := digitGlyphs[current[i][j]-1]
+			x0 := pngMargin + j*pngSquare + (pngSquare-5*pngScale)/2
+			y0 := pngMargin + i*pngSquare + (pngSquare-7*pngScale)/2
+			for gy, line := range glyph {
+				for gx, px := range line {
+					if px == '#' {
+						fill(x0+gx*pngScale, y0+gy*pngScale, x0+(gx+1)*pngScale, y0+(gy+1)*pngScale, c)
+					}
+				}
+			}
+		}
+	}
+	// The light lines go in first, so that the heavy ones are drawn over them where they meet.
+	for _, heavy := range []bool{false, true} {
+		for i := 0; i <= 9; i++ {
+			for j := 0; j <= 9; j++ {
+				x, y := pngMargin+j*pngSquare, pngMargin+i*pngSquare
+				// The line along the top of square i, j, and the line down its left side.
+				if j < 9 && (i == 0 || i == 9 || blockOf[i-1][j] != blockOf[i][j]) == heavy {
+					if heavy {
+						fill(x-1, y-1, x+pngSquare+2, y+2, pngGivenColour)
+					} else {
+						fill(x, y, x+pngSquare, y+1, pngLineColour)
+					}
+				}
+				if i < 9 && (j == 0 || j == 9 || blockOf[i][j-1] != blockOf[i][j]) == heavy {
+					if heavy {
+						fill(x-1, y-1, x+2, y+pngSquare+2, pngGivenColour)
+					} else {
+						fill(x, y, x+1, y+pngSquare, pngLineColour)
+					}
+				}
+			}
+		}
+	}
+	f, err := os.Create(name)
+	if err != nil {
+		return fmt.Errorf("Unable to create file %s: %v", name, err)
+	}
+	err = png.Encode(f, img)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return fmt.Errorf("Error writing file %s: %v", name, err)
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+// preview.go
+//
+// Working out what the next round would rule out without doing it, for a front end that shows the player what is about to change.  The
+// analysis of each row, column and block and the techniques that span the whole grid are run one after the other in the caller's
+// goroutine, on the board as the last solve left it, and the messages they would have sent to the buffer channel are collected instead.
+package main
+
+import "sort"
+
+// pendingMsgs, when set, collects the messages bufferMsg would otherwise send, for PendingEliminations.
+var pendingMsgs *[]updateMsg
+
+// PendingEliminations returns every value the techniques that are turned on would rule out in the next round, from the board of the
+// last solve, or hint, as it stands, without changing it.  There is one Elimination for each square and technique, with Technique set,
+// ordered by row, column and technique; a value placed in a square rules out the rest of its values.  The clears a finalized square
+// makes in the squares it sees are not included, as the solve has already made them.  It must not be called while a solve runs.
+func PendingEliminations() (elims []Elimination) {
+	var msgs []updateMsg
+	pendingMsgs = &msgs
+	// A contradiction found along the way is left for the next solve to find again, so the outcome of the last one still stands.
+	savedNoSolution, savedContradiction := noSolution.Load(), contradiction
+	defer func() {
+		pendingMsgs = nil
+		noSolution.Store(savedNoSolution)
+		contradiction = savedContradiction
+	}()
+	for _, msg := range analysisMsgs() {
+		for _, found := range analyse(msg) {
+			bufferMsg(found)
+		}
+	}
+	inspectGrid()
+
+	type key struct {
+		r, c   int
+		reason technique
+	}
+	index := map[key]int{}
+	for _, msg := range msgs {
+		sqr := board[msg.destR][msg.destC]
+		if sqr.isFinal {
+			continue
+		}
+		vals := sqr.possVal & msg.val
+		if msg.action == set {
+			vals = sqr.possVal.Remove(msg.val)
+		}
+		if vals == 0 {
+			continue
+		}
+		k := key{msg.destR, msg.destC, msg.reason}
+		if n, ok := index[k]; ok {
+			elims[n].Values = elims[n].Values.Add(vals)
+			continue
+		}
+		index[k] = len(elims)
+		elims = append(elims, Elimination{msg.destR, msg.destC, vals, string(msg.reason)})
+	}
+	sort.Slice(elims, func(a, b int) bool {
+		ea, eb := elims[a], elims[b]
+		if ea.Row != eb.Row {
+			return ea.Row < eb.Row
+		}
+		if ea.Col != eb.Col {
+			return ea.Col < eb.Col
+		}
+		return ea.Technique < eb.Technique
+	})
+	return
+}
@@ -0,0 +1,100 @@
+// score.go
+//
+// A difficulty score from 1 to 10, for rate -score, so that puzzles can be sorted by difficulty where the sets of techniques rate finds
+// cannot.  The puzzle is solved with each deduction credited to the easiest technique that makes it, and the score starts from the
+// weight of the hardest technique that changed the board.  It goes up by one for a puzzle that needed the hard techniques, of weight 5
+// or more, for at least five changes, and again at fifteen, and by one for a puzzle with fewer than 25 givens, up to 9.  A puzzle the
+// techniques cannot solve scores 10.
+package main
+
+import "sort"
+
+// techniqueWeights rates each technique from 1 to 9 by how hard it is to spot.  A technique registered with RegisterTechnique, which is
+// not listed, has customWeight.  Naked singles are not listed, as a square they finalize has had its other values cleared by the
+// techniques that count.
+var techniqueWeights = map[technique]int{
+	solvedPeer: 1, hiddenSingle: 1,
+	pointing: 2, claiming: 2, diagonalPointing: 2,
+	nakedPair: 3, hiddenPair: 3, cageSum: 3,
+	nakedTriple: 4, hiddenTriple: 4,
+	nakedQuad: 5, hiddenQuad: 5, xWing: 5, skyscraper: 5, emptyRectangle: 5,
+	xyWing: 6, remotePair: 6, swordfish: 6,
+	xyzWing: 7, jellyfish: 7,
+	alignedPairExclusion: 8, sueDeCoq: 8,
+	alternatingInferenceChain: 9,
+}
+
+const customWeight = 5
+
+// hardWeight is the weight from which a technique counts as hard, for the uses that raise the score.
+const hardWeight = 5
+
+func techniqueWeight(t technique) int {
+	if w, ok := techniqueWeights[t]; ok {
+		return w
+	}
+	return customWeight
+}
+
+// Rate returns the difficulty score of the puzzle g, with 0 for an unknown square, from 1 for a puzzle that only needs singles to 10 for
+// one the techniques that are turned on cannot solve, or 0 if it has no solution.  The hardest technique needed is found by solving
+// with the techniques of weight 1, then of weight up to 2, and so on, until the puzzle solves, and the hard uses are counted in that
+// solve.
+func Rate(g [9][9]int) int {
+	savedDisabled, savedOrder := disabled, techniqueOrder
+	defer func() { disabled, techniqueOrder = savedDisabled, savedOrder }()
+	// Put the techniques in order of weight, so that a deduction both an easy and a hard technique make is credited to the easy one.
+	techniqueOrder = append([]technique{solvedPeer}, optionalTechniques...)
+	sort.SliceStable(techniqueOrder, func(a, b int) bool {
+		return techniqueWeight(techniqueOrder[a]) < techniqueWeight(techniqueOrder[b])
+	})
+	hardest := 0
+	for w := 1; w <= 9 && hardest == 0; w++ {
+		disabled = map[technique]bool{}
+		added := w == 1
+		for _, t := range optionalTechniques {
+			switch tw := techniqueWeight(t); {
+			case savedDisabled[t] || tw > w:
+				disabled[t] = true
+			case tw == w && (advanced || !isAdvanced(t)):
+				added = true
+			}
+		}
+		if !added {
+			continue // the same techniques as the last solve, which stalled
+		}
+		solve(g, solveOptions{})
+		if noSolution.Load() {
+			return 0
+		}
+		if !stalled {
+			hardest = w
+		}
+	}
+	if hardest == 0 {
+		return 10
+	}
+
+	hardUses := 0
+	historyMu.Lock()
+	for _, m := range history[:historyPos] {
+		if m.round > 0 && techniqueWeight(m.reason) >= hardWeight {
+			hardUses++
+		}
+	}
+	historyMu.Unlock()
+	score := hardest
+	if hardUses >= 5 {
+		score++
+	}
+	if hardUses >= 15 {
+		score++
+	}
+	if countGivens(g) < 25 {
+		score++
+	}
+	if score > 9 {
+		score = 9
+	}
+	return score
+}
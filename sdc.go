@@ -0,0 +1,124 @@
+// sdc.go
+//
+// Sue de Coq, which works where a block crosses a row or column.  Take two or three unsolved squares C of the crossing whose possible
+// values V number at least two more than the squares, then squares D from the rest of the line and E from the rest of the block, with
+// no value possible in both D and E, such that C, D and E together have exactly as many possible values as squares.  A value of D can
+// only go once among C and D, which are all in the line, a value of E once among C and E, in the block, and a value of V in neither
+// once among C; so every one of those values goes in exactly one of the squares.  The values of D, and those of V that E cannot take,
+// are then placed in the line by C and D, and can be cleared from the rest of it; the values of E, and those of V that D cannot take,
+// likewise from the rest of the block.  Trying every such set of squares is expensive, so it is only done with -advanced.
+package main
+
+const sueDeCoq technique = "sue-de-coq"
+
+func init() {
+	advancedTechniques = append(advancedTechniques, sueDeCoq)
+}
+
+// squareSet is a set of unsolved squares and the values possible in any of them.
+type squareSet struct {
+	pos    []gridPos
+	values squareVal
+}
+
+// squareSubsets returns every non-empty subset of ps with at most max squares.
+func squareSubsets(ps []gridPos, max int) (sets []squareSet) {
+	for subset := 1; subset < 1<<len(ps); subset++ {
+		var s squareSet
+		for k, p := range ps {
+			if subset&(1<<k) != 0 {
+				s.pos = append(s.pos, p)
+				s.values = s.values.Add(board[p.r][p.c].possVal)
+			}
+		}
+		if len(s.pos) <= max {
+			sets = append(sets, s)
+		}
+	}
+	return
+}
+
+func checkSueDeCoq() {
+	// The same value is often cleared more than once, so the clears are gathered up and sent once at the end.
+	var clears [9][9]squareVal
+	for b := 0; b < 9; b++ {
+		for line := 0; line < 18; line++ {
+			// Lines 0 to 8 are the rows, and 9 to 17 the columns.
+			inLine := func(p gridPos) bool {
+				if line < 9 {
+					return p.r == line
+				}
+				return p.c == line-9
+			}
+			var cross, blockRest, lineRest []gridPos
+			for k := 0; k < 9; k++ {
+				p := gridPos{line, k}
+				if line >= 9 {
+					p = gridPos{k, line - 9}
+				}
+				if !board[p.r][p.c].isFinal && blockOf[p.r][p.c] != b {
+					lineRest = append(lineRest, p)
+				}
+			}
+			for _, p := range blockSquares[b] {
+				switch {
+				case board[p.r][p.c].isFinal:
+				case inLine(p):
+					cross = append(cross, p)
+				default:
+					blockRest = append(blockRest, p)
+				}
+			}
+			if len(cross) < 2 || len(lineRest) == 0 || len(blockRest) == 0 {
+				continue
+			}
+			lineSets, blockSets := squareSubsets(lineRest, maxALSSize), squareSubsets(blockRest, maxALSSize)
+			for _, c := range squareSubsets(cross, 3) {
+				if len(c.pos) < 2 || c.values.Count() < len(c.pos)+2 {
+					continue
+				}
+				for _, d := range lineSets {
+					if !d.values.Has(c.values) {
+						continue
+					}
+					for _, e := range blockSets {
+						if !e.values.Has(c.values) || d.values.Has(e.values) ||
+							c.values.Add(d.values).Add(e.values).Count() != len(c.pos)+len(d.pos)+len(e.pos) {
+							continue
+						}
+						lineVals := d.values.Add(c.values.Remove(e.values))
+						blockVals := e.values.Add(c.values.Remove(d.values))
+						in := map[gridPos]bool{}
+						for _, s := range [][]gridPos{c.pos, d.pos, e.pos} {
+							for _, p := range s {
+								in[p] = true
+							}
+						}
+						for _, p := range lineRest {
+							if !in[p] {
+								clears[p.r][p.c] = clears[p.r][p.c].Add(board[p.r][p.c].possVal & lineVals)
+							}
+						}
+						for _, p := range blockRest {
+							if !in[p] {
+								clears[p.r][p.c] = clears[p.r][p.c].Add(board[p.r][p.c].possVal & blockVals)
+							}
+						}
+						for _, p := range cross {
+							if !in[p] {
+								clears[p.r][p.c] = clears[p.r][p.c].Add(board[p.r][p.c].possVal & lineVals.Add(blockVals))
+							}
+						}
+					}
+				}
+			}
+		}
+	}
+	for i := 0; i < 9; i++ {
+		for j := 0; j < 9; j++ {
+			if clears[i][j] != 0 {
+				clearIfPossible(clears[i][j], i, j, sueDeCoq)
+			}
+		}
+	}
+}
@@ -0,0 +1,47 @@
+// selfcheck.go
+//
+// A debugging aid for the techniques.  With solve -selfcheck, the solution is first found by the backtracking search in AllSolutions,
+// and then every set and clear message is checked against it on its way through bufferMsg.  A message that sets a square to anything
+// but its solution, or clears its solution from it, is a bug in the technique that sent it, and is reported with the square, the
+// value and the technique.
+package main
+
+import (
+	"fmt"
+	"os"
+	"sync"
+)
+
+var selfCheckMu sync.Mutex
+
+// selfCheckFaults holds the faulty messages reported during the solve, so that each is only reported once however often it is resent.
+var selfCheckFaults = map[updateMsg]bool{}
+
+// checkMove reports msg if it contradicts opts.solution.  It is called by any of the square monitors, and by the round looper.
+func checkMove(msg updateMsg) {
+	v := opts.solution[msg.destR][msg.destC]
+	bit := one << (v - 1)
+	if (msg.action != set || msg.val == bit) && (msg.action != clear || msg.val&bit == 0) {
+		return
+	}
+	selfCheckMu.Lock()
+	defer selfCheckMu.Unlock()
+	if selfCheckFaults[msg] {
+		return
+	}
+	selfCheckFaults[msg] = true
+	if msg.action == set {
+		fmt.Fprintf(os.Stderr, "Self-check: %s set row %d column %d to %s, but the solution has %c there\n",
+			msg.reason, msg.destR+1, msg.destC+1, valuesString(msg.val), symbols[v-1])
+	} else {
+		fmt.Fprintf(os.Stderr, "Self-check: %s cleared %c from row %d column %d, but the solution has it there\n",
+			msg.reason, symbols[v-1], msg.destR+1, msg.destC+1)
+	}
+}
+
+// selfCheckFailed reports whether checkMove has found any faults during the solve.
+func selfCheckFailed() bool {
+	selfCheckMu.Lock()
+	defer selfCheckMu.Unlock()
+	return len(selfCheckFaults) > 0
+}
@@ -0,0 +1,29 @@
+// snapshot.go
+//
+// Watching a solve from another goroutine, for a progress bar or a live view.  The board cannot be read safely while the square
+// monitors are changing it, so a copy is published at the points where they are all idle: the givens as the solve starts, and then from
+// the round looper once the board has been set up, at the end of each round, and at the end of the solve.  Snapshot returns the latest
+// copy, and may be called at any time.
+package main
+
+import "sync"
+
+var snapshotMu sync.Mutex
+var snapshotGrid [9][9]int
+var snapshotRound int
+
+// publishSnapshot makes g, the board after round rounds, the one Snapshot returns.
+func publishSnapshot(g [9][9]int, round int) {
+	snapshotMu.Lock()
+	snapshotGrid, snapshotRound = g, round
+	snapshotMu.Unlock()
+}
+
+// Snapshot returns the board of the solve that is running, or of the last one, as it stood at the end of the latest round, with 0 for
+// a square not yet finalized, along with the number of rounds that had been completed.  It is safe to call from any goroutine while a
+// solve runs.
+func Snapshot() (grid [9][9]int, round int) {
+	snapshotMu.Lock()
+	defer snapshotMu.Unlock()
+	return snapshotGrid, snapshotRound
+}
@@ -0,0 +1,110 @@
+// state.go
+//
+// Saving the board part way through, with every square's possible values rather than just the finalized ones, so that a long analysis
+// can be stopped and carried on later.  The state is nine lines of nine squares separated by spaces, each square written as the symbols
+// of its possible values, with = in front of a finalized square.  A solve that starts from a saved state sets the finalized squares as
+// givens and clears the values the state had already ruled out from the rest, before the first round.
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+const savedState technique = "saved-state" // the value had already been ruled out in the state the solve was resumed from
+
+// resumeState is the state read by LoadState, which the next solve starts from in place of its grid, or nil.
+var resumeState *[9][9]squareVal
+
+// SaveState writes the board as it stands to w.  It must only be called while the square monitors are idle, such as after a solve.
+func SaveState(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	for i := 0; i < 9; i++ {
+		squares := make([]string, 9)
+		for j := 0; j < 9; j++ {
+			squares[j] = valuesString(board[i][j].possVal)
+			if board[i][j].isFinal {
+				squares[j] = "=" + squares[j]
+			}
+		}
+		fmt.Fprintln(bw, strings.Join(squares, " "))
+	}
+	return bw.Flush()
+}
+
+// LoadState reads a state written by SaveState from r, for the next solve to carry on from.
+func LoadState(r io.Reader) error {
+	state, err := readState(r)
+	if err != nil {
+		return err
+	}
+	resumeState = &state
+	return nil
+}
+
+func readState(r io.Reader) (state [9][9]squareVal, err error) {
+	symToInt := symbolToInt()
+	scanner := bufio.NewScanner(r)
+	scanner.Split(scanLines)
+	i := 0
+	for ; scanner.Scan(); i++ {
+		squares := strings.Fields(scanner.Text())
+		if i >= 9 {
+			return state, inputErrorf(-1, -1, "the state has more than 9 lines")
+		}
+		if len(squares) != 9 {
+			return state, inputErrorf(i, -1, "line %d of the state has %d squares, expected 9", i+1, len(squares))
+		}
+		for j, square := range squares {
+			final := strings.HasPrefix(square, "=")
+			for _, sym := range strings.TrimPrefix(square, "=") {
+				if v := symToInt[sym]; v != 0 {
+					state[i][j] = state[i][j].Add(one << (v - 1))
+				} else {
+					return state, inputErrorf(i, j, "line %d, square %d of the state has %q, which is not a value", i+1, j+1, sym)
+				}
+			}
+			if state[i][j] == 0 || (final && !state[i][j].IsSingle()) {
+				return state, inputErrorf(i, j, "line %d, square %d of the state must have one value if finalized, or at least one if not", i+1, j+1)
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return state, err
+	}
+	if i != 9 {
+		return state, inputErrorf(-1, -1, "the state has %d lines, expected 9", i)
+	}
+	return state, nil
+}
+
+// stateGrid returns the squares of state that have only one possible value, as a grid of givens.
+func stateGrid(state [9][9]squareVal) (g [9][9]int) {
+	for i := 0; i < 9; i++ {
+		for j := 0; j < 9; j++ {
+			if state[i][j].IsSingle() {
+				g[i][j] = state[i][j].Values()[0]
+			}
+		}
+	}
+	return
+}
+
+// saveStateFile writes the board as it stands to the file name, for solve -save-state.
+func saveStateFile(name string) error {
+	f, err := os.Create(name)
+	if err != nil {
+		return fmt.Errorf("Unable to create file %s: %v", name, err)
+	}
+	err = SaveState(f)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return fmt.Errorf("Error writing file %s: %v", name, err)
+	}
+	return nil
+}
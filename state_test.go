@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestStateRoundTrip(t *testing.T) {
+	defer setSymbols("123456789", "0")
+	defer func() { resumeState = nil }()
+	puzzle, _, err := readBoard("FriDec4-2020")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		syms, blank string
+		notIn       string // characters the saved state must not have
+	}{
+		{"123456789", "0", "ABCDEFGHI"},
+		{"ABCDEFGHI", ".", "123456789"},
+	} {
+		if err := setSymbols(tc.syms, tc.blank); err != nil {
+			t.Fatal(err)
+		}
+		// Stop after the first round, when some squares are finalized and others still have several values.
+		solve(puzzle, solveOptions{atRound: 1, out: io.Discard})
+		want := boardState()
+		finals, open := 0, 0
+		for i := 0; i < 9; i++ {
+			for j := 0; j < 9; j++ {
+				if board[i][j].isFinal {
+					finals++
+				} else if want[i][j].Count() > 1 {
+					open++
+				}
+			}
+		}
+		if finals == 0 || open == 0 {
+			t.Fatalf("%s: the board after a round has %d squares finalized and %d open", tc.syms, finals, open)
+		}
+
+		var buf bytes.Buffer
+		if err := SaveState(&buf); err != nil {
+			t.Fatal(err)
+		}
+		saved := buf.String()
+		if strings.ContainsAny(saved, tc.notIn) {
+			t.Errorf("%s: the state is not written with the symbols:\n%s", tc.syms, saved)
+		}
+		if err := LoadState(strings.NewReader(saved)); err != nil {
+			t.Fatalf("%s: reading back the state: %v", tc.syms, err)
+		}
+		if *resumeState != want {
+			t.Errorf("%s: the state read back is not the state saved", tc.syms)
+		}
+
+		// Setting the board to what was read and saving it again gives the same text.
+		setBoard(*resumeState)
+		buf.Reset()
+		if err := SaveState(&buf); err != nil {
+			t.Fatal(err)
+		}
+		if buf.String() != saved {
+			t.Errorf("%s: saved again, the state reads\n%s\nnot\n%s", tc.syms, buf.String(), saved)
+		}
+
+		// Carrying on from the state read back finishes the puzzle as a solve from the start does.
+		solve(puzzle, solveOptions{out: io.Discard})
+		resumed := boardGrid()
+		solve(puzzle, solveOptions{out: io.Discard})
+		if stalled || resumed != boardGrid() {
+			t.Errorf("%s: the solve carried on from the state does not finish as one from the start", tc.syms)
+		}
+	}
+}
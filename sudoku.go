@@ -3,7 +3,8 @@
 //
 // This program will solve a Sudoku using the same techniques a human uses to solve Sudoku.  I.e., instead of doing a breadth first or depth first search of the possible
 // solution space, it will at each step only commit numbers to squares when that number is provably correct, based entirely on what state has been deduced so far.
-// The program is structured with a go rountine monitoring each of the 81 squares of the grid, one per square.  These respond to messages on an inbound channel.  The message actions
+// The program is structured with a go rountine monitoring each of the 9 rows of the grid, and so the 9 squares in that row.  These respond to messages on an inbound channel, each addressed
+// to one square of the row.  The message actions
 // are either state modifying, which are the set and clear actions.  Set is used to initially set the value of the square if it is known as an initial state (the squares that have numbers
 // to seed the puzzle.).  It is also used when the value of a square has been determined to be one of the nine possible numbers.  The clear action is used to reduce the possible
 // values a square may have.  Much of the logic of puzzle solving is to reduce the possible values of a square, eventually to a single value.  The values a square may have are stored as a bit
@@ -17,8 +18,8 @@
 // 2. if in a row, column, or block, there is only one place where a particular value can be placed.
 // 3. if in a row, for example, the only place a value can be placed is within a group of three squares that are in the same block, then that value can not be placed elsewhere in that block.  The same
 // holds in reverse, and the same holds between columns and blocks.
-// 4. If two (or three) values are contrained to two (or three) squares in a row, column or block, then those two or three squares cannot hold any other value.
-// 5. If two (or three) squares all hold the same two (or three) values and no other possible values, then those values cannot appear elsewhere in the row, column or block.
+// 4. If two, three or four values are contrained to as many squares in a row, column or block, then those squares cannot hold any other value.
+// 5. If two, three or four squares between them hold only as many values, then those values cannot appear elsewhere in the row, column or block.
 //
 // The square monitor threads are the only threads that can change the value (or possible value) of a square, and they do so only at a presribed time, described as the beginning of a round.
 // A round consists of a period where the square monitors process set and clear messages from their queues.  As they do that, they can send set and clear messages to other squares in their row, column or block.
@@ -27,21 +28,29 @@
 // listening on their incoming channel.  When the round looper wakes on the round counter waitgroup going to zero, it will forward all enqueued messages to the listening square monitors.  However, it will not forward
 // additional messages as they arrive - it inspects the cnt of messages in its channel and forwards only that number.  It then sends a pause message to each square monitor.  When that phase of the round is complete,
 // the round looper will send 27 messages to 27 of the square monitor threads, each of which initiates that thread to do analysis of one row, column or block.  This is where more complex scenarios are discovered, as
-// described in 4 and 5 above.  Those threads will send new set and clear messages, which again a enqueued on the round loopers buffer channel, and are fowarded to the square monitors only after the waitgroup goes to zero.
+// described in 4 and 5 above.  The analysis only reads the board, and returns the new set and clear messages it deduces; once every thread is done, the round looper enqueues them on its buffer
+// channel, and they are fowarded to the square monitors in the next phase.
 //
 // The entire program begins to wrap up once a waitgroup that counts the number of remaining unfinalized squares goes to zero.  At that point, an abort channel is closed, which acts as a broadcast to all threads to
 // clean up and exit.  As each thread exits, it releases its hold on a thread count wait group.  All channels are closed.  When all threads except main have completed, main will complete and the program exits.
 //
 // Output to stdout is completed at the beginning when the puzzle's initial values are known, and at the completion of each round.  The output uses unicode characters to print a sudoku board.  The quality of this
 // presentation depends on the character renderings in the terminal or printer, but for the most part, the boards are very legible.  An html output would possibly give a better rendering.
-//
 package main
 
 import (
+	"bytes"
+	"encoding/binary"
 	"fmt"
+	"hash/fnv"
+	"io"
 	"math/bits"
 	"os"
+	"sort"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"unicode/utf8"
 )
 
 type squareVal uint16
@@ -59,8 +68,70 @@ const (
 )
 const blank = one | two | three | four | five | six | seven | eight | nine
 
-const maxBufferchan = 40 * 20
-const maxInchan = 50
+// Count returns how many values v holds.
+func (v squareVal) Count() int {
+	return bits.OnesCount16(uint16(v))
+}
+
+// Has reports whether v holds any of the values in val, which is usually a single value.  With more than one value in val it is true if
+// v holds at least one of them, not only if it holds them all: Sue de Coq relies on that to ask whether two sets of values meet.  Use
+// v&val == val to ask for all of them.
+func (v squareVal) Has(val squareVal) bool {
+	return v&val != 0
+}
+
+// Add returns v with the values in val added.
+func (v squareVal) Add(val squareVal) squareVal {
+	return v | val
+}
+
+// Remove returns v with the values in val taken out.
+func (v squareVal) Remove(val squareVal) squareVal {
+	return v &^ val
+}
+
+// IsSingle reports whether v holds exactly one value, as a finalized square does.
+func (v squareVal) IsSingle() bool {
+	return v.Count() == 1
+}
+
+// Values returns the values v holds as numbers from 1 to 9, in order.
+func (v squareVal) Values() []int {
+	vals := make([]int, 0, v.Count())
+	v.each(func(val squareVal) {
+		vals = append(vals, bits.TrailingZeros16(uint16(val))+1)
+	})
+	return vals
+}
+
+// each calls f with each of the values v holds, as a squareVal of its own, from one up to nine.
+func (v squareVal) each(f func(val squareVal)) {
+	for val := one; val <= nine; val <<= 1 {
+		if v.Has(val) {
+			f(val)
+		}
+	}
+}
+
+// bufferChanSize is the capacity of the buffer channel, set by -buffer-size.  Every message sent in a phase waits there until the next
+// phase, so it must hold the most any phase sends; the default has room for every square to be given, each clearing its peers (up to 32
+// in the X variant) before the first round.  A solve that overfills it panics.
+var bufferChanSize = 81 * 32
+
+// inChanSize is the capacity of the channel of each row, set by -row-buffer-size.  The round looper forwards the messages of a phase
+// while the square monitors are taking them off, so a smaller channel only has it wait for them more often; the default, 50 for each of
+// the nine squares, is more than a row is usually sent in a phase.
+var inChanSize = 50 * 9
+
+// monitors is how many square monitors a solve runs, each owning the next 81/monitors squares in row order, which must divide evenly:
+// 9 gives each row a monitor, 81 gives each square one, as an earlier version did, and 1 runs the whole board on one.  BenchmarkSolve
+// times each.
+var monitors = 9
+
+// monitorOf returns the number of the square monitor that owns the square at r, c.
+func monitorOf(r, c int) int {
+	return (r*9 + c) / (81 / monitors)
+}
 
 type action int
 
@@ -71,6 +142,7 @@ const (
 	analyseRow
 	analyseCol
 	analyseBlock
+	analyseDiagonal
 )
 
 type rcbSelect int
@@ -81,58 +153,174 @@ const (
 	block
 )
 
+// technique names the deduction behind a set or clear message, so that a square can report how it came to be finalized.
+type technique string
+
+const (
+	given        technique = "given"
+	solvedPeer   technique = "solved-peer" // a square in the same row, column or block was finalized to the value
+	nakedSingle  technique = "naked-single"
+	hiddenSingle technique = "hidden-single"
+	pointing     technique = "pointing"
+	claiming     technique = "claiming"
+	hiddenPair   technique = "hidden-pair"
+	hiddenTriple technique = "hidden-triple"
+	nakedPair    technique = "naked-pair"
+	nakedTriple  technique = "naked-triple"
+	hiddenQuad   technique = "hidden-quad"
+	nakedQuad    technique = "naked-quad"
+)
+
+// optionalTechniques are the techniques that can be turned off from the command line, to see whether a puzzle still solves without
+// them.  The others follow directly from the rules, and nothing could be solved without them.
+var optionalTechniques = []technique{hiddenSingle, pointing, claiming, hiddenPair, hiddenTriple, hiddenQuad, nakedPair, nakedTriple,
+	nakedQuad, emptyRectangle, skyscraper, xWing, swordfish, jellyfish, xyWing, xyzWing, remotePair, diagonalPointing, cageSum,
+	alignedPairExclusion, sueDeCoq, alternatingInferenceChain}
+
+// disabled holds the techniques turned off from the command line.  Their deductions are dropped by bufferMsg as they are made, so the
+// solve goes on as if they had never been implemented.
+var disabled = map[technique]bool{}
+
 type updateMsg struct {
-	val    squareVal
+	val    squareVal // the values to set or clear, or for analyseBlock, the number of the block
 	action action
 	destR  int
 	destC  int
+	reason technique
 }
 
 type square struct {
-	possVal squareVal
-	inChan  chan updateMsg
-	isFinal bool
+	possVal  squareVal
+	inChan   chan updateMsg // shared by all the squares of a row, since one monitor owns the row
+	isFinal  bool
+	solvedBy technique
+}
+
+// solveOptions control a single run of the square monitors and the round looper.
+type solveOptions struct {
+	showRounds        bool       // print the board at the start of each round and at the end
+	compact           bool       // print the board as nine lines of digits rather than drawn with box characters, for narrow terminals
+	stopAtFirstSolved bool       // stop as soon as any square that was not given has been finalized
+	atRound           int        // if not zero, print the board at the end of this round (or at the end, if sooner) and stop there
+	maxRounds         int        // if not zero, give up as stalled if the puzzle is not solved after this many rounds
+	answersOnly       bool       // print the board only at the end, with the givens left empty, for an answer key
+	labels            bool       // letter the columns and number the rows around the board drawn with box characters
+	traceStrings      bool       // print each board as one line of the 81 squares in row order, for another program to read
+	histogram         bool       // print how many squares have each number of possible values left, at the start of each round and at the end
+	solution          *[9][9]int // if set, check every set and clear message against this solution, for -selfcheck
+	out               io.Writer  // where the boards and histograms are printed; os.Stdout if nil
 }
 
+// symbols holds the characters that stand for the values one through nine, in that order, both in a puzzle file and on the printed
+// board.  blankSymbol marks a square with no initial value in a puzzle file.  These can be replaced from the command line so the
+// same engine can solve variant puzzles that are labelled A-I or with other glyphs.
+var symbols = []rune("123456789")
+var blankSymbol = '0'
+
+var opts solveOptions
+var stalled bool
+var outOfRounds bool       // set, along with stalled, when the last solve gave up at opts.maxRounds
+var roundsRun int          // the number of rounds the last solve started
+var phasesRun int          // the number of times the last solve forwarded the messages buffered in a phase
+var noSolution atomic.Bool // set by whichever goroutine first finds that the puzzle contradicts itself
+
 var abortChan chan struct{}
 var bufferChan chan updateMsg
 var board [9][9]square
+
+// phaseFinal holds whether each square had been finalized when the phase began, for sendUpdates.  A square monitor only changes the
+// squares it owns, so it can read isFinal there as it goes; the others are being changed by their own monitors in the same phase, so it
+// reads them from here instead, which the round looper sets while every monitor is idle.
+var phaseFinal [9][9]bool
 var wgRound sync.WaitGroup
 
 var wgSqrsDone sync.WaitGroup
 var wgThrdsDone sync.WaitGroup
 var wgRCB sync.WaitGroup
 
-func main() {
-	if len(os.Args) < 2 {
-		fmt.Fprintf(os.Stderr, "Insufficient args, missing input filename.\n")
-		os.Exit(1)
+func solve(grid [9][9]int, o solveOptions) {
+	// A state read by LoadState is only used by the one solve.
+	start := resumeState
+	resumeState = nil
+	if start != nil {
+		grid = stateGrid(*start)
+	}
+	opts = o
+	if opts.out == nil {
+		opts.out = os.Stdout
+	}
+	stalled = false
+	outOfRounds = false
+	roundsRun = 0
+	phasesRun = 0
+	publishSnapshot(grid, 0)
+	noSolution.Store(false)
+	contradiction = nil
+	if conflict := givensConflict(grid); conflict != "" {
+		contradict("", "%s", conflict)
+	}
+	resetHistory()
+	lastChain = nil
+	selfCheckFaults = map[updateMsg]bool{}
+	if noSolution.Load() {
+		return
+	}
+	if countGivens(grid) == 81 {
+		// Every square is given, so there is nothing to deduce.  Set the board as it stands and check the constraints that
+		// givensConsistent does not, without starting the square monitors and the round looper at all.
+		for i := 0; i < 9; i++ {
+			for j := 0; j < 9; j++ {
+				board[i][j].possVal = one << (grid[i][j] - 1)
+				board[i][j].isFinal = true
+				board[i][j].solvedBy = given
+			}
+		}
+		checkFinishedGrid(grid)
+		if opts.showRounds || opts.atRound > 0 || opts.answersOnly {
+			showBoard()
+		}
+		if opts.histogram {
+			writeHistogram(opts.out, "Final", boardState())
+		}
+		return
 	}
 	abortChan = make(chan struct{})
 	wgRound.Add(9 * 9)
 	wgSqrsDone.Add(9 * 9)
-	wgThrdsDone.Add(9*9 + 1)
-	// sentCnt and rcvdCnt arrays should both be initialized to 0
-	for i := 0; i < 9; i++ {
-		for j := 0; j < 9; j++ {
+	wgThrdsDone.Add(monitors + 1)
+	for m := 0; m < monitors; m++ {
+		// inChanSize is for the nine squares of a row, so a monitor's channel is sized for the squares it owns.
+		inChan := make(chan updateMsg, inChanSize*81/monitors/9)
+		for k := m * 81 / monitors; k < (m+1)*81/monitors; k++ {
+			i, j := k/9, k%9
 			board[i][j].possVal = blank
-			board[i][j].inChan = make(chan updateMsg, maxInchan)
+			board[i][j].inChan = inChan
 			board[i][j].isFinal = false
-			go squareMonitor(i, j)
+			board[i][j].solvedBy = ""
+			phaseFinal[i][j] = false
 		}
+		go squareMonitor(m)
 	}
-	bufferChan = make(chan updateMsg, maxBufferchan)
+	bufferChan = make(chan updateMsg, bufferChanSize)
 	go roundLooper()
 
-	err := captureBoard(os.Args[1])
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-		os.Exit(1)
-	}
-	wgSqrsDone.Wait()
-	//close(abortChan)
-	close(bufferChan)
+	captureBoard(grid, start)
+	// roundLooper closes abortChan once the puzzle is finished, which tells every square monitor to exit.  Only when all of the
+	// threads that can send on bufferChan are done is it safe to close it.
 	wgThrdsDone.Wait()
+	close(bufferChan)
+}
+
+// checkFinishedGrid records a contradiction if g, with every square finalized, is not a solution after all.
+func checkFinishedGrid(g [9][9]int) {
+	switch conflict := givensConflict(g); {
+	case conflict != "":
+		contradict("", "the finished grid is wrong: %s", conflict)
+	case xVariant && !diagonalsValid(g):
+		contradict("", "the finished grid is wrong: a diagonal repeats a value")
+	case !cagesValid(g):
+		contradict("", "the finished grid is wrong: a cage does not add up to its sum")
+	}
 }
 
 func roundLooper() {
@@ -141,18 +329,42 @@ func roundLooper() {
 		// First check capacity
 		cnt := len(bufferChan)
 		if cnt == cap(bufferChan) {
-			panic("buffer channel is full, this is bad")
+			panic(fmt.Sprintf("buffer channel is full, this is bad: raise -buffer-size from %d", cap(bufferChan)))
 		}
 
-		// Forward all the enqueued messages
-		if cnt > 0 {
-			for msg := range bufferChan {
-				board[msg.destR][msg.destC].inChan <- msg
-				cnt--
-				if cnt == 0 {
-					break
-				}
+		// Forward all the enqueued messages.  They arrive in whatever order the square monitors happened to send them, so sort them
+		// first; each square then sees the same messages in the same order on every run, which keeps the solving trace reproducible.
+		msgs := make([]updateMsg, cnt)
+		for k := range msgs {
+			msgs[k] = <-bufferChan
+		}
+		sort.Slice(msgs, func(a, b int) bool {
+			ma, mb := msgs[a], msgs[b]
+			if ma.destR != mb.destR {
+				return ma.destR < mb.destR
+			}
+			if ma.destC != mb.destC {
+				return ma.destC < mb.destC
+			}
+			if ma.action != mb.action {
+				return ma.action < mb.action
+			}
+			if ma.val != mb.val {
+				return ma.val < mb.val
+			}
+			if ra, rb := techniqueRank(ma.reason), techniqueRank(mb.reason); ra != rb {
+				return ra < rb
 			}
+			return ma.reason < mb.reason
+		})
+		phasesRun++
+		for i := 0; i < 9; i++ {
+			for j := 0; j < 9; j++ {
+				phaseFinal[i][j] = board[i][j].isFinal
+			}
+		}
+		for _, msg := range msgs {
+			board[msg.destR][msg.destC].inChan <- msg
 		}
 	}
 
@@ -166,100 +378,270 @@ func roundLooper() {
 		wgRound.Add(81)
 	}
 
-	abortFlag := false
+	sqrsDone := make(chan struct{})
 	go func() {
 		wgSqrsDone.Wait()
-		abortFlag = true
+		close(sqrsDone)
 	}()
+	// isDone is only called while the square monitors are idle, so it reads the board rather than sqrsDone, which the watcher above may
+	// not have closed yet when the last square was finalized in the phase just ended.
+	isDone := func() bool {
+		for i := 0; i < 9; i++ {
+			for j := 0; j < 9; j++ {
+				if !board[i][j].isFinal {
+					return false
+				}
+			}
+		}
+		return true
+	}
+
+	stopEarly := func() bool {
+		if noSolution.Load() {
+			return true
+		}
+		if !opts.stopAtFirstSolved {
+			return false
+		}
+		for i := 0; i < 9; i++ {
+			for j := 0; j < 9; j++ {
+				if board[i][j].isFinal && board[i][j].solvedBy != given {
+					return true
+				}
+			}
+		}
+		return false
+	}
 
 	wgRound.Wait()  // All square monitor goroutines have quiesced.
 	wgRound.Add(81) // Reset the worker wait group for the next round
-	//loop:
-	for !abortFlag {
+	lastState := boardState()
+	lastHash := stateHash(lastState)
+	// The blocks and the variant stay the same for the whole solve, so the analysis messages only need making once.
+	analysis := analysisMsgs()
+	round := 0
+	publishSnapshot(boardGrid(), round)
+loop:
+	for !isDone() {
+		roundsRun++
 		// Collect messages from each round, wait for each round to quiesce, then distribute messages to next round
-		displayBoard()
+		if opts.showRounds {
+			showBoard()
+		}
+		if opts.histogram {
+			writeHistogram(opts.out, fmt.Sprintf("Round %d", round), boardState())
+		}
 		forwardMsgs()
 		pauseMonitors()
-		wgRCB.Add(27)
-		inspectRCB()
+		if stopEarly() {
+			break loop
+		}
+		wgRCB.Add(len(analysis))
+		inspectRCB(analysis)
 		wgRCB.Wait()
+		for _, msg := range analysisFound {
+			bufferMsg(msg)
+		}
+		analysisFound = analysisFound[:0]
+		// The square monitors are all idle now, so the board can be read safely from here for the techniques that span the whole grid.
+		inspectGrid()
+		// If neither the first phase, in clearing the peers of the squares it finalized, nor the analysis and the techniques that span the
+		// whole grid have sent anything, no later phase or round can change the board, so the solve has stalled already; there is no
+		// need to run the second phase, and another round, only to find the board the same.
+		if len(bufferChan) == 0 && !noSolution.Load() && !isDone() {
+			stalled = true
+			break loop
+		}
 		forwardMsgs()
 		pauseMonitors()
+		if stopEarly() {
+			break loop
+		}
+		// If a whole round has not changed the possible values of any square, the implemented techniques can go no further.
+		state := boardState()
+		hash := stateHash(state)
+		if unchangedState(state, hash, lastState, lastHash) && !isDone() {
+			stalled = true
+			break loop
+		}
+		lastState, lastHash = state, hash
+		round++
+		publishSnapshot(boardGrid(), round)
+		if round == opts.atRound {
+			break loop
+		}
+		if round == opts.maxRounds && !isDone() {
+			stalled, outOfRounds = true, true
+			break loop
+		}
 	}
-	displayBoard()
-	wgThrdsDone.Done()
+	if isDone() {
+		checkFinishedGrid(boardGrid())
+	}
+	publishSnapshot(boardGrid(), round)
+	if opts.showRounds || opts.atRound > 0 || opts.answersOnly {
+		showBoard()
+	}
+	if opts.histogram {
+		writeHistogram(opts.out, "Final", boardState())
+	}
+	// pauseMonitors left wgRound armed for a round that will not run; release it so the next solve starts from zero.
+	wgRound.Add(-81)
+	// Broadcast the shutdown before releasing main, so that main cannot close bufferChan while a square monitor could still send on it.
 	close(abortChan)
+	// Every unfinalized square releases wgSqrsDone as it exits, so wait for the watcher to see it reach zero before the next solve reuses it.
+	<-sqrsDone
+	wgThrdsDone.Done()
 }
 
-func inspectRCB() {
+// analysisMsgs returns the messages that start the analysis phase of a round, one for each row, column and block, and each diagonal of
+// the X variant.  Each goes to a square in the unit it analyses, which tells the square monitor which unit that is, and they are spread
+// over the rows so that every square monitor gets a share of the work: row i to the square on the main diagonal, column c to row c-1
+// (row 8 for column 0), block b to blockAnchor[b], and the diagonals to the squares at the top of them.  A block is not always easy to
+// tell from a square of it, so the message for a block also carries its number.
+func analysisMsgs() (msgs []updateMsg) {
 	for i := 0; i < 9; i++ {
-		board[i][i].inChan <- updateMsg{action: analyseRow}
+		msgs = append(msgs, updateMsg{action: analyseRow, destR: i, destC: i})
 	}
 	for i := 0; i < 9; i++ {
-		board[i][(i+1)%9].inChan <- updateMsg{action: analyseCol}
+		msgs = append(msgs, updateMsg{action: analyseCol, destR: i, destC: (i + 1) % 9})
+	}
+	for b, p := range blockAnchor {
+		msgs = append(msgs, updateMsg{val: squareVal(b), action: analyseBlock, destR: p.r, destC: p.c})
+	}
+	if xVariant {
+		msgs = append(msgs, updateMsg{action: analyseDiagonal, destR: 0, destC: 0}, updateMsg{action: analyseDiagonal, destR: 0, destC: 8})
+	}
+	return
+}
+
+func inspectRCB(msgs []updateMsg) {
+	for _, msg := range msgs {
+		board[msg.destR][msg.destC].inChan <- msg
+	}
+}
+
+// analysisFound collects the set and clear messages deduced by the analysis phase of a round, from whichever square monitors did the
+// analysis, for the round looper to buffer once the phase is over.
+var analysisFound []updateMsg
+var analysisMu sync.Mutex
+
+// analyse runs the analysis of the row, column, block or diagonal that msg, one of analysisMsgs, asks for, and returns the set and clear
+// messages it deduces that would change the board.  A unit whose analysis finds nothing new returns none, even if it deduces values
+// that are already cleared again, as it does every round once a unit is worked out.
+func analyse(msg updateMsg) []updateMsg {
+	var found []updateMsg
+	switch msg.action {
+	case analyseRow:
+		found = inspectRow(msg.destR, msg.destC)
+	case analyseCol:
+		found = inspectCol(msg.destR, msg.destC)
+	case analyseBlock:
+		found = inspectBlock(int(msg.val))
+	case analyseDiagonal:
+		found = inspectDiagonal(msg.destR, msg.destC)
+	default:
+		panic("not an analysis message")
 	}
-	for i := 0; i < 9; i += 3 {
-		for j := 2; j < 9; j += 3 {
-			board[i][j].inChan <- updateMsg{action: analyseBlock}
+	var msgs []updateMsg
+	for _, m := range found {
+		if changesBoard(m) {
+			msgs = append(msgs, m)
 		}
 	}
+	return msgs
 }
 
-func squareMonitor(i, j int) {
-	sqr := &board[i][j]
+// changesBoard reports whether the set or clear message msg would change the board as it stands, or show that the puzzle has no
+// solution, rather than clearing values that are already gone or setting a square to the value it already has.  It must only be called
+// while the board is not changing.
+func changesBoard(msg updateMsg) bool {
+	sqr := &board[msg.destR][msg.destC]
+	if msg.action == set {
+		return sqr.possVal != msg.val
+	}
+	return !sqr.isFinal && sqr.possVal&msg.val != 0
+}
+
+// squareMonitor runs monitor m, which owns the squares monitorOf gives it, with the rows of the usual nine.  Every message on its
+// channel carries the row and column of the square it is for, except for pause, which is sent once per square and only counts towards
+// the round barrier.
+func squareMonitor(m int) {
+	first := m * 81 / monitors
+	inChan := board[first/9][first%9].inChan
 outerloop:
 	for {
 		select {
-		case msg := <-sqr.inChan:
+		case msg := <-inChan:
+			i, j := msg.destR, msg.destC
+			sqr := &board[i][j]
 			switch msg.action {
 			case set:
 				if sqr.isFinal {
 					continue outerloop
 				}
+				if !sqr.possVal.Has(msg.val) {
+					// Another deduction has already ruled the value out for this square.
+					contradict(msg.reason, "cell R%dC%d has no candidates left, as %s had already been ruled out there", i+1, j+1,
+						valuesString(msg.val))
+					continue outerloop
+				}
 				if sqr.possVal != msg.val {
+					before := sqr.possVal
 					sqr.possVal = msg.val
-					if finalCheckVal(sqr.possVal) {
+					if sqr.possVal.IsSingle() {
 						sqr.isFinal = true
-						sendUpdates(i, j, updateMsg{msg.val, clear, -1, -1})
+						sqr.solvedBy = msg.reason
+						sendUpdates(i, j, updateMsg{msg.val, clear, -1, -1, solvedPeer})
 						// WaitGroup 3 triggers completion of sudoku when all squares have been finalized
 						wgSqrsDone.Add(-1)
 					}
+					if msg.reason != given {
+						recordMove(i, j, before, msg.reason)
+					}
 				}
 			case clear:
 				if sqr.isFinal {
 					continue outerloop
 				}
-				newval := sqr.possVal &^ msg.val
+				newval := sqr.possVal.Remove(msg.val)
 				if newval == sqr.possVal {
 					// no change to square value
 					continue
+				} else if newval == 0 {
+					// Every value has been ruled out for this square.
+					contradict(msg.reason, "cell R%dC%d has no candidates left once %s is ruled out", i+1, j+1, valuesString(msg.val))
+					continue
 				} else {
+					before := sqr.possVal
 					sqr.possVal = newval
-					if finalCheckVal(sqr.possVal) {
+					if sqr.possVal.IsSingle() {
 						sqr.isFinal = true
-						sendUpdates(i, j, updateMsg{newval, clear, -1, -1})
+						sqr.solvedBy = nakedSingle
+						sendUpdates(i, j, updateMsg{newval, clear, -1, -1, solvedPeer})
 						// WaitGroup 3 triggers completion of sudoku when all squares have been finalized
 						wgSqrsDone.Add(-1)
 					}
+					recordMove(i, j, before, msg.reason)
 				}
 			case pause:
 				wgRound.Done() // Waitgroup 1 tracks the number of squares that are still active in this round.
-			case analyseRow:
-				inspectRow(i, j)
-				wgRCB.Done()
-			case analyseCol:
-				inspectCol(i, j)
-				wgRCB.Done()
-			case analyseBlock:
-				inspectBlock(i, j)
+			case analyseRow, analyseCol, analyseBlock, analyseDiagonal:
+				found := analyse(msg)
+				analysisMu.Lock()
+				analysisFound = append(analysisFound, found...)
+				analysisMu.Unlock()
 				wgRCB.Done()
 			default:
 				panic("Should always have an action")
 			}
 		case <-abortChan:
-			// Global abort signal received (via main closing the abortChan)
-			if !sqr.isFinal {
-				panic("should not get here if wg is zero")
+			// Global abort signal received (via the round looper closing the abortChan).  If the puzzle stalled, or the round looper
+			// stopped early, some squares may not be finalized yet; release their hold on the wait group so it can be reused.
+			for k := first; k < first+81/monitors; k++ {
+				if !board[k/9][k%9].isFinal {
+					wgSqrsDone.Done()
+				}
 			}
 			wgThrdsDone.Done()
 			break outerloop
@@ -273,11 +655,11 @@ func sendUpdates(r, c int, msg updateMsg) {
 		if j == c {
 			continue
 		} else {
-			if !board[r][j].isFinal {
-				// The isFinal check is an optimization to reduce the number of messages sent to finalized squares.  No lock needed on board[r][j]
+			if !peerFinal(r, c, r, j) {
+				// The isFinal check is an optimization to reduce the number of messages sent to finalized squares.
 				msg.destR = r
 				msg.destC = j
-				bufferChan <- msg
+				bufferMsg(msg)
 			}
 		}
 	}
@@ -286,526 +668,452 @@ func sendUpdates(r, c int, msg updateMsg) {
 		if i == r {
 			continue
 		} else {
-			if !board[i][c].isFinal {
+			if !peerFinal(r, c, i, c) {
 				msg.destR = i
 				msg.destC = c
-				bufferChan <- msg
+				bufferMsg(msg)
 			}
 		}
 	}
 	// Update the remainder of the block (not in the same row or column as the sending square)
-	rb := r / 3 * 3
-	cb := c / 3 * 3
-	for i := rb; i < rb+3; i++ {
-		for j := cb; j < cb+3; j++ {
-			if i == r || j == c {
-				// We have already notified squares in the same row and column
-				continue
-			} else {
-				if !board[i][j].isFinal {
-					msg.destR = i
-					msg.destC = j
-					bufferChan <- msg
-				}
+	for _, p := range blockSquares[blockOf[r][c]] {
+		if p.r == r || p.c == c {
+			// We have already notified squares in the same row and column
+			continue
+		} else {
+			if !peerFinal(r, c, p.r, p.c) {
+				msg.destR = p.r
+				msg.destC = p.c
+				bufferMsg(msg)
 			}
 		}
 	}
+	if xVariant {
+		sendDiagonalUpdates(r, c, msg)
+	}
+}
+
+// peerFinal reports whether the square at i, j is finalized, as far as the monitor of the square at r, c can tell while the other
+// monitors are running: as it stands for a square the same monitor owns, and as it was at the start of the phase for any other.  A
+// square finalized since then is only sent a message it will ignore.
+func peerFinal(r, c, i, j int) bool {
+	if monitorOf(i, j) == monitorOf(r, c) {
+		return board[i][j].isFinal
+	}
+	return phaseFinal[i][j]
+}
+
+func bufferMsg(msg updateMsg) {
+	// All messages bound for the next round go through here.  Once abortChan is closed the round looper is no longer draining
+	// bufferChan, so the message is dropped rather than blocking or sending on a channel that main is about to close.  Nor does the
+	// round looper drain it while messages are being sent, only between phases, so a send that finds it full would wait forever.
+	if disabled[msg.reason] {
+		return
+	}
+	if pendingMsgs != nil {
+		*pendingMsgs = append(*pendingMsgs, msg)
+		return
+	}
+	if opts.solution != nil {
+		checkMove(msg)
+	}
+	select {
+	case <-abortChan:
+	default:
+		select {
+		case bufferChan <- msg:
+		case <-abortChan:
+		default:
+			panic(fmt.Sprintf("buffer channel is full, this is bad: raise -buffer-size from %d", cap(bufferChan)))
+		}
+	}
 }
 
-func inspectRow(r, c int) {
+func inspectRow(r, c int) (msgs []updateMsg) {
 	// Count and locate each possible number in the remaining squares
 	colPos := make(map[squareVal][]int)
 	unplacedValues := blank
 	for val := one; val <= nine; val <<= 1 {
 		for j := 0; j < 9; j++ {
-			if board[r][j].possVal&val == val {
+			if board[r][j].possVal.Has(val) {
 				// square could be this value
 				colPos[val] = append(colPos[val], j)
 			}
 		}
 		if len(colPos[val]) == 0 {
-			panic("this is a problem, number not found in row")
+			// The value has nowhere left to go in this row.
+			contradict("", "row %d has no place for value %s", r+1, valuesString(val))
+			return
 		}
 		// Check for previously unknown singletons in the row
 		if len(colPos[val]) == 1 {
-			unplacedValues &^= val
+			unplacedValues = unplacedValues.Remove(val)
 			cPos := colPos[val][0]
 			if !board[r][cPos].isFinal {
-				bufferChan <- updateMsg{val, set, r, cPos}
+				msgs = append(msgs, updateMsg{val, set, r, cPos, hiddenSingle})
 			}
 		} else {
 			// Check if all possible locations for the number are within the same block
-			cPosLow := colPos[val][0]
-			cPosLast := len(colPos[val]) - 1
-			cPosHigh := colPos[val][cPosLast]
-			if cPosLow/3 == cPosHigh/3 {
-				// All instances of the number are in the same block.
-				cb := cPosLow / 3 * 3
-				rb := r / 3 * 3
-				for ri := rb; ri < rb+3; ri++ {
-					if ri == r {
-						continue
-					}
-					for ci := cb; ci < cb+3; ci++ {
-						bufferChan <- updateMsg{val, clear, ri, ci}
+			b := blockOf[r][colPos[val][0]]
+			sameBlock := true
+			for _, cPos := range colPos[val] {
+				sameBlock = sameBlock && blockOf[r][cPos] == b
+			}
+			if sameBlock {
+				// Claiming, from a line to the block: all instances of the number in the row are in the same block, so the row takes it
+				// there, and it cannot be anywhere else in the block.
+				for _, p := range blockSquares[b] {
+					if p.r != r {
+						msgs = append(msgs, updateMsg{val, clear, p.r, p.c, claiming})
 					}
 				}
 			}
 		}
 	}
 	// Do some harder Sudoku solving.
-	checkConstrainedSquares(unplacedValues, r, row, colPos)
-	checkConstrainedValues(r, row)
+	msgs = append(msgs, checkConstrainedSquares(unplacedValues, r, row, colPos)...)
+	return append(msgs, checkConstrainedValues(r, row)...)
 }
 
-func inspectCol(r, c int) {
+func inspectCol(r, c int) (msgs []updateMsg) {
 	// Count and locate each possible number in the remaining squares
 	rowPos := make(map[squareVal][]int)
 	unplacedValues := blank
 	for val := one; val <= nine; val <<= 1 {
 		for i := 0; i < 9; i++ {
-			if board[i][c].possVal&val == val {
+			if board[i][c].possVal.Has(val) {
 				// square could be this value
 				rowPos[val] = append(rowPos[val], i)
 			}
 		}
 		if len(rowPos[val]) == 0 {
-			panic("this is a problem, number not found in column")
+			// The value has nowhere left to go in this column.
+			contradict("", "column %d has no place for value %s", c+1, valuesString(val))
+			return
 		}
 		// Check for previously unknown singletons in the column
 		if len(rowPos[val]) == 1 {
-			unplacedValues &^= val
+			unplacedValues = unplacedValues.Remove(val)
 			rPos := rowPos[val][0]
 			if !board[rPos][c].isFinal {
-				bufferChan <- updateMsg{val, set, rPos, c}
+				msgs = append(msgs, updateMsg{val, set, rPos, c, hiddenSingle})
 			}
 		} else {
 			// Check if all possible locations for the number are within the same block
-			rPosLow := rowPos[val][0]
-			rPosLast := len(rowPos[val]) - 1
-			rPosHigh := rowPos[val][rPosLast]
-			if rPosLow/3 == rPosHigh/3 {
-				// All instances of the number are in the same block.
-				rb := rPosLow / 3 * 3
-				cb := c / 3 * 3
-				for ci := cb; ci < cb+3; ci++ {
-					if ci == c {
-						continue
-					}
-					for ri := rb; ri < rb+3; ri++ {
-						bufferChan <- updateMsg{val, clear, ri, ci}
+			b := blockOf[rowPos[val][0]][c]
+			sameBlock := true
+			for _, rPos := range rowPos[val] {
+				sameBlock = sameBlock && blockOf[rPos][c] == b
+			}
+			if sameBlock {
+				// Claiming, from a line to the block: all instances of the number in the column are in the same block, so the column takes it
+				// there, and it cannot be anywhere else in the block.
+				for _, p := range blockSquares[b] {
+					if p.c != c {
+						msgs = append(msgs, updateMsg{val, clear, p.r, p.c, claiming})
 					}
 				}
 			}
 		}
 	}
 	// Do some harder Sudoku solving.
-	checkConstrainedSquares(unplacedValues, c, column, rowPos)
-	checkConstrainedValues(c, column)
+	msgs = append(msgs, checkConstrainedSquares(unplacedValues, c, column, rowPos)...)
+	return append(msgs, checkConstrainedValues(c, column)...)
 }
 
-func inspectBlock(r, c int) {
-	type blockPosStruct struct {
-		r int
-		c int
-	}
+func inspectBlock(b int) (msgs []updateMsg) {
 	unplacedValues := blank
-	blockRowPos := make(map[squareVal][]blockPosStruct)
-	blockColPos := make(map[squareVal][]blockPosStruct)
-	rb := r / 3 * 3
-	cb := c / 3 * 3
+	// The positions within the block, counting across each row of it in turn, where each value is still possible.
+	blockPos := make(map[squareVal][]int)
 	// Count and locate each possible number in the remaining squares
 	for val := one; val <= nine; val <<= 1 {
-		for i := rb; i < rb+3; i++ {
-			for j := cb; j < cb+3; j++ {
-				if board[i][j].possVal&val == val {
-					// square could be this value
-					blockRowPos[val] = append(blockRowPos[val], blockPosStruct{i, j})
-				}
-			}
-		}
-		for j := cb; j < cb+3; j++ {
-			for i := rb; i < rb+3; i++ {
-				if board[i][j].possVal&val == val {
-					// square could be this value
-					blockColPos[val] = append(blockColPos[val], blockPosStruct{i, j})
-				}
+		for k, p := range blockSquares[b] {
+			if board[p.r][p.c].possVal.Has(val) {
+				// square could be this value
+				blockPos[val] = append(blockPos[val], k)
 			}
 		}
-		if len(blockRowPos[val]) != len(blockColPos[val]) {
-			panic("these should be equal")
-		}
-		if len(blockRowPos[val]) == 0 {
-			panic("this is a problem, number not found in block")
+		if len(blockPos[val]) == 0 {
+			// The value has nowhere left to go in this block.
+			contradict("", "block %d has no place for value %s", b+1, valuesString(val))
+			return
 		}
 		// Check for previously unknown singletons in the block
-		if len(blockRowPos[val]) == 1 {
-			rPos := blockRowPos[val][0].r
-			cPos := blockRowPos[val][0].c
-			unplacedValues &^= val
-			if !board[rPos][cPos].isFinal {
-				bufferChan <- updateMsg{val, set, rPos, cPos}
+		if len(blockPos[val]) == 1 {
+			p := blockSquares[b][blockPos[val][0]]
+			unplacedValues = unplacedValues.Remove(val)
+			if !board[p.r][p.c].isFinal {
+				msgs = append(msgs, updateMsg{val, set, p.r, p.c, hiddenSingle})
 			}
 		} else {
 			// Check if all possible locations for the number are within the same row or column
-			blockPosLast := len(blockRowPos[val]) - 1
-			rPosLow := blockRowPos[val][0].r
-			rPosHigh := blockRowPos[val][blockPosLast].r
-			cPosLow := blockColPos[val][0].c
-			cPosHigh := blockColPos[val][blockPosLast].c
-			if rPosLow == rPosHigh {
+			first := blockSquares[b][blockPos[val][0]]
+			sameRow, sameCol := true, true
+			for _, k := range blockPos[val] {
+				sameRow = sameRow && blockSquares[b][k].r == first.r
+				sameCol = sameCol && blockSquares[b][k].c == first.c
+			}
+			// Pointing, from the block to a line: the block takes the number in that row or column, so it cannot be anywhere else
+			// in the line.  The other way round, from a line to a block, is claiming, in inspectRow and inspectCol.
+			if sameRow {
 				// All possible locations of the number in this block are in the same row.
-				for ri := rb; ri < rb+3; ri++ {
-					if ri == rPosLow {
-						continue
-					}
-					for ci := cb; ci < cb+3; ci++ {
-						bufferChan <- updateMsg{val, clear, ri, ci}
+				for j := 0; j < 9; j++ {
+					if blockOf[first.r][j] != b {
+						msgs = append(msgs, updateMsg{val, clear, first.r, j, pointing})
 					}
 				}
 			}
-			if cPosLow == cPosHigh {
+			if sameCol {
 				// All possible locations of the number in this block are in the same column.
-				for ci := cb; ci < cb+3; ci++ {
-					if ci == cPosLow {
-						continue
-					}
-					for ri := rb; ri < rb+3; ri++ {
-						bufferChan <- updateMsg{val, clear, ri, ci}
+				for i := 0; i < 9; i++ {
+					if blockOf[i][first.c] != b {
+						msgs = append(msgs, updateMsg{val, clear, i, first.c, pointing})
 					}
 				}
 			}
 		}
 	}
-	blockPos := make(map[squareVal][]int)
-	for val := one; val <= nine; val <<= 1 {
-		for _, bp := range blockRowPos[val] {
-			i := bp.r % 3
-			j := bp.c % 3
-			blockPos[val] = append(blockPos[val], 3*i+j)
+	msgs = append(msgs, checkConstrainedSquares(unplacedValues, b, block, blockPos)...)
+	return append(msgs, checkConstrainedValues(b, block)...)
+}
+
+// hiddenSubsets and nakedSubsets name the techniques for a group of two, three or four squares found by checkConstrainedSquares and
+// checkConstrainedValues.
+var hiddenSubsets = map[int]technique{2: hiddenPair, 3: hiddenTriple, 4: hiddenQuad}
+var nakedSubsets = map[int]technique{2: nakedPair, 3: nakedTriple, 4: nakedQuad}
+
+// forEachSubset calls f with every subset of exactly n of the bits set in mask.
+func forEachSubset(mask uint16, n int, f func(subset uint16)) {
+	var choose func(rest, subset uint16, n int)
+	choose = func(rest, subset uint16, n int) {
+		if n == 0 {
+			f(subset)
+			return
+		}
+		for rest != 0 {
+			low := rest & -rest
+			rest &^= low
+			choose(rest, subset|low, n-1)
 		}
 	}
-	rb /= 3
-	cb /= 3
-	checkConstrainedSquares(unplacedValues, 3*rb+cb, block, blockPos)
-	checkConstrainedValues(3*rb+cb, block)
+	choose(mask, 0, n)
 }
 
-func checkConstrainedSquares(unplacedValues squareVal, rcb int, isRCB rcbSelect, rcbPos map[squareVal][]int) {
-	// If two values are only found in two squares, then those squares cannot have any other value.
-	if bits.OnesCount16(uint16(unplacedValues)) > 2 {
-		for val1 := one; val1 <= eight; val1 <<= 1 {
-			if unplacedValues&val1 == 0 {
-				continue
-			}
-			for val2 := val1 << 1; val2 <= nine; val2 <<= 1 {
-				if unplacedValues&val2 == 0 {
-					continue
-				}
-				posArray := make([]int, 0, 3)
-				var posMap uint16
-				cnt := 0
-				for _, i := range rcbPos[val1] {
-					if cnt > 2 {
-						break
-					}
-					if posMap&(1<<i) == 0 {
-						posMap |= 1 << i
-						posArray = append(posArray, i)
-						cnt++
-					}
-				}
-				for _, i := range rcbPos[val2] {
-					if cnt > 2 {
-						break
-					}
-					if posMap&(1<<i) == 0 {
-						posMap |= 1 << i
-						posArray = append(posArray, i)
-						cnt++
-					}
+// rcbSquare returns the square at position j of row, column or block rcb.  Positions in a block run across each row of the block in turn,
+// in the order of blockSquares.
+func rcbSquare(rcb int, isRCB rcbSelect, j int) (r, c int) {
+	switch isRCB {
+	case row:
+		return rcb, j
+	case column:
+		return j, rcb
+	}
+	p := blockSquares[rcb][j]
+	return p.r, p.c
+}
+
+func checkConstrainedSquares(unplacedValues squareVal, rcb int, isRCB rcbSelect, rcbPos map[squareVal][]int) (msgs []updateMsg) {
+	// If n values (n = 2, 3 or 4) are only found in n squares, then those squares cannot have any other value.
+	for n := 2; n <= 4 && unplacedValues.Count() > n; n++ {
+		forEachSubset(uint16(unplacedValues), n, func(vals uint16) {
+			var posMap uint16
+			squareVal(vals).each(func(val squareVal) {
+				for _, j := range rcbPos[val] {
+					posMap |= 1 << j
 				}
-				if cnt == 2 {
-					// These two values can only be placed in two squares.  Clear all other possible values of those squares.
-					clearVal := blank &^ (val1 | val2)
-					switch isRCB {
-					case row:
-						bufferChan <- updateMsg{clearVal, clear, rcb, posArray[0]}
-						bufferChan <- updateMsg{clearVal, clear, rcb, posArray[1]}
-					case column:
-						bufferChan <- updateMsg{clearVal, clear, posArray[0], rcb}
-						bufferChan <- updateMsg{clearVal, clear, posArray[1], rcb}
-					case block:
-						rblock, cblock := rcb/3*3, rcb%3*3
-						bufferChan <- updateMsg{clearVal, clear, rblock + posArray[0]/3, cblock + posArray[0]%3}
-						bufferChan <- updateMsg{clearVal, clear, rblock + posArray[1]/3, cblock + posArray[0]%3}
-					}
+			})
+			if bits.OnesCount16(posMap) != n {
+				return
+			}
+			// These n values can only be placed in n squares.  Clear all other possible values of those squares.
+			clearVal := blank &^ squareVal(vals)
+			for j := 0; j < 9; j++ {
+				if posMap&(1<<j) != 0 {
+					r, c := rcbSquare(rcb, isRCB, j)
+					msgs = append(msgs, updateMsg{clearVal, clear, r, c, hiddenSubsets[n]})
 				}
 			}
-		}
+		})
 	}
+	return
+}
 
-	// If three values are only found in three squares, then those squares cannot have any other value.
-	if bits.OnesCount16(uint16(unplacedValues)) > 3 {
-		for val1 := one; val1 <= seven; val1 <<= 1 {
-			if unplacedValues&val1 == 0 {
-				continue
-			}
-			for val2 := val1 << 1; val2 <= eight; val2 <<= 1 {
-				if unplacedValues&val2 == 0 {
-					continue
+func checkConstrainedValues(rcb int, isRCB rcbSelect) (msgs []updateMsg) {
+	// If n squares (n = 2, 3 or 4) can only hold the same n values between them and no others, then clear those values from the rest of
+	// the row, column or block.  The squares of a pair are not left out of the search for a triple or quad, so a triple holding a pair is
+	// found in the same round as the pair.
+	var unresolved uint16
+	for j := 0; j < 9; j++ {
+		r, c := rcbSquare(rcb, isRCB, j)
+		if !board[r][c].isFinal {
+			unresolved |= 1 << j
+		}
+	}
+	for n := 2; n <= 4 && bits.OnesCount16(unresolved) > n; n++ {
+		forEachSubset(unresolved, n, func(sqrs uint16) {
+			var mergeVal squareVal
+			for j := 0; j < 9; j++ {
+				if sqrs&(1<<j) != 0 {
+					r, c := rcbSquare(rcb, isRCB, j)
+					mergeVal |= board[r][c].possVal
 				}
-				for val3 := val2 << 1; val3 <= nine; val3 <<= 1 {
-					if unplacedValues&val2 == 0 {
-						continue
-					}
-					posArray := make([]int, 0, 4)
-					var posMap uint16
-					cnt := 0
-					for _, i := range rcbPos[val1] {
-						if cnt > 3 {
-							break
-						}
-						if posMap&(1<<i) == 0 {
-							posMap |= 1 << i
-							posArray = append(posArray, i)
-							cnt++
-						}
-					}
-					for _, i := range rcbPos[val2] {
-						if cnt > 3 {
-							break
-						}
-						if posMap&(1<<i) == 0 {
-							posMap |= 1 << i
-							posArray = append(posArray, i)
-							cnt++
-						}
-					}
-					for _, i := range rcbPos[val3] {
-						if cnt > 3 {
-							break
-						}
-						if posMap&(1<<i) == 0 {
-							posMap |= 1 << i
-							posArray = append(posArray, i)
-							cnt++
-						}
-					}
-					if cnt == 3 {
-						// These three values can only be placed in three squares.  Clear all other possible values of those squares.
-						clearVal := blank &^ (val1 | val2 | val3)
-						switch isRCB {
-						case row:
-							bufferChan <- updateMsg{clearVal, clear, rcb, posArray[0]}
-							bufferChan <- updateMsg{clearVal, clear, rcb, posArray[1]}
-							bufferChan <- updateMsg{clearVal, clear, rcb, posArray[2]}
-						case column:
-							bufferChan <- updateMsg{clearVal, clear, posArray[0], rcb}
-							bufferChan <- updateMsg{clearVal, clear, posArray[1], rcb}
-							bufferChan <- updateMsg{clearVal, clear, posArray[2], rcb}
-						case block:
-							rblock, cblock := rcb/3*3, rcb%3*3
-							bufferChan <- updateMsg{clearVal, clear, rblock + posArray[0]/3, cblock + posArray[0]%3}
-							bufferChan <- updateMsg{clearVal, clear, rblock + posArray[1]/3, cblock + posArray[1]%3}
-							bufferChan <- updateMsg{clearVal, clear, rblock + posArray[2]/3, cblock + posArray[2]%3}
-						}
-					}
+			}
+			if mergeVal.Count() != n {
+				return
+			}
+			for j := 0; j < 9; j++ {
+				r, c := rcbSquare(rcb, isRCB, j)
+				if unresolved&^sqrs&(1<<j) != 0 {
+					msgs = append(msgs, updateMsg{mergeVal, clear, r, c, nakedSubsets[n]})
 				}
 			}
-		}
+		})
 	}
+	return
 }
 
-func checkConstrainedValues(rcb int, isRCB rcbSelect) {
-	// If two squares can only hold the same two values and no others, then clear those values from the rest of the row, column or block.
-	var pvCnt [9]int
-	var sqrPaired [9]bool
-	unresolvedCnt := 0
+// boardState returns the possible values of every square.  Between phases, every square monitor is idle, so it must only be called
+// then, or after a solve.
+func boardState() (state [9][9]squareVal) {
+	for i := 0; i < 9; i++ {
+		for j := 0; j < 9; j++ {
+			state[i][j] = board[i][j].possVal
+		}
+	}
+	return
+}
 
-	blockpos := func(b, j int) (r, c int) {
-		r = b/3*3 + j/3
-		c = b%3*3 + j%3
-		return
+// stateHash returns a hash of the possible values of every square of state, FNV-1a over the 81 squares in row order, so that two states
+// with different hashes are certainly different.
+func stateHash(state [9][9]squareVal) uint64 {
+	h := fnv.New64a()
+	var buf [2]byte
+	for i := 0; i < 9; i++ {
+		for j := 0; j < 9; j++ {
+			binary.LittleEndian.PutUint16(buf[:], uint16(state[i][j]))
+			h.Write(buf[:])
+		}
 	}
+	return h.Sum64()
+}
 
-	for j := 0; j < 9; j++ {
-		switch isRCB {
-		case row:
-			pvCnt[j] = bits.OnesCount16(uint16(board[rcb][j].possVal))
-		case column:
-			pvCnt[j] = bits.OnesCount16(uint16(board[j][rcb].possVal))
-		case block:
-			r, c := blockpos(rcb, j)
-			pvCnt[j] = bits.OnesCount16(uint16(board[r][c].possVal))
-		}
-		if pvCnt[j] >= 2 {
-			unresolvedCnt++
-		}
-	}
-	if unresolvedCnt > 2 {
-		for j1 := 0; j1 < 8; j1++ {
-			if pvCnt[j1] != 2 {
-				continue
-			}
-			for j2 := j1 + 1; j2 < 9; j2++ {
-				if pvCnt[j2] != 2 {
-					continue
-				}
-				var possVal1, possVal2 squareVal
-				switch isRCB {
-				case row:
-					possVal1 = board[rcb][j1].possVal
-					possVal2 = board[rcb][j2].possVal
-				case column:
-					possVal1 = board[j1][rcb].possVal
-					possVal2 = board[j2][rcb].possVal
-				case block:
-					r1, c1 := blockpos(rcb, j1)
-					r2, c2 := blockpos(rcb, j2)
-					possVal1 = board[r1][c1].possVal
-					possVal1 = board[r2][c2].possVal
-				}
-				if possVal1 == possVal2 {
-					// We found a match of two squares that have the same two possible values. Clear those values from other squares in the row, column or block.
-					sqrPaired[j1] = true
-					sqrPaired[j2] = true
-				loop2:
-					for j := 0; j < 9; j++ {
-						var r, c int
-						if j == j1 || j == j2 {
-							continue loop2
-						}
-						switch isRCB {
-						case row:
-							r, c = rcb, j
-						case column:
-							r, c = j, rcb
-						case block:
-							r, c = blockpos(rcb, j)
-						}
-						if board[r][c].isFinal {
-							continue loop2
-						}
-						bufferChan <- updateMsg{possVal1, clear, r, c}
-					}
-				}
+// unchangedState reports whether state, whose stateHash is hash, is the same as last, whose stateHash is lastHash.  The hashes tell almost
+// every changed board apart at once; only when they match are the boards compared square by square, so two boards whose hashes collide
+// are still told apart.
+func unchangedState(state [9][9]squareVal, hash uint64, last [9][9]squareVal, lastHash uint64) bool {
+	return hash == lastHash && sameState(state, last)
+}
+
+// sameState reports whether a and b have the same possible values in every square.
+func sameState(a, b [9][9]squareVal) bool {
+	for i := 0; i < 9; i++ {
+		for j := 0; j < 9; j++ {
+			if a[i][j] != b[i][j] {
+				return false
 			}
 		}
 	}
-	// If three squares can only hold the same three values and no others, then clear those values from the rest of the row, column or block.
-	if unresolvedCnt > 3 {
-		for j1 := 0; j1 < 7; j1++ {
-			if sqrPaired[j1] {
-				continue
-			}
-			if pvCnt[j1] != 2 && pvCnt[j1] != 3 {
-				continue
-			}
-			for j2 := j1 + 1; j2 < 8; j2++ {
-				if sqrPaired[j2] {
-					continue
-				}
-				if pvCnt[j2] != 2 && pvCnt[j2] != 3 {
-					continue
-				}
-				for j3 := j2 + 1; j3 < 9; j3++ {
-					if sqrPaired[j3] {
-						continue
-					}
-					if pvCnt[j3] != 2 && pvCnt[j3] != 3 {
-						continue
-					}
-					var mergeVal squareVal
-					switch isRCB {
-					case row:
-						mergeVal = board[rcb][j1].possVal | board[rcb][j2].possVal | board[rcb][j3].possVal
-					case column:
-						mergeVal = board[j1][rcb].possVal | board[j2][rcb].possVal | board[j3][rcb].possVal
-					case block:
-						r1, c1 := blockpos(rcb, j1)
-						r2, c2 := blockpos(rcb, j2)
-						r3, c3 := blockpos(rcb, j3)
-						mergeVal = board[r1][c1].possVal | board[r2][c2].possVal | board[r3][c3].possVal
-					}
-					if bits.OnesCount16(uint16(mergeVal)) == 3 {
-						// Found a match of three unresolved squares that each have two or three of the same three possible values
-					loop3:
-						for j := 0; j < 9; j++ {
-							var r, c int
-							if j == j1 || j == j2 || j == j3 {
-								continue loop3
-							}
-							switch isRCB {
-							case row:
-								r, c = rcb, j
-							case column:
-								r, c = j, rcb
-							case block:
-								r, c = blockpos(rcb, j)
-							}
-							if board[r][c].isFinal {
-								continue loop3
-							}
-							bufferChan <- updateMsg{mergeVal, clear, r, c}
-						}
-					}
-				}
+	return true
+}
+
+// boardGrid returns the board as a grid of ints, with 0 for each square that has not been finalized.  It must only be called while the
+// square monitors are idle.
+func boardGrid() (g [9][9]int) {
+	for i := 0; i < 9; i++ {
+		for j := 0; j < 9; j++ {
+			if board[i][j].isFinal {
+				g[i][j] = board[i][j].possVal.Values()[0]
 			}
 		}
 	}
+	return
 }
 
-func finalCheckVal(val squareVal) (rv bool) {
-	if bits.OnesCount16(uint16(val)) == 1 {
-		rv = true
-	} else {
-		rv = false
+// writeHistogram writes a line to w, under the given label, of how many squares of state have each number of possible values left, from
+// 1 for a finalized square up to 9.
+func writeHistogram(w io.Writer, label string, state [9][9]squareVal) {
+	var counts [10]int
+	for i := 0; i < 9; i++ {
+		for j := 0; j < 9; j++ {
+			counts[state[i][j].Count()]++
+		}
 	}
-	return
+	fmt.Fprintf(w, "%s:", label)
+	for n := 1; n <= 9; n++ {
+		fmt.Fprintf(w, " %d:%d", n, counts[n])
+	}
+	fmt.Fprintln(w)
 }
 
-func captureBoard(inFileName string) error {
-	inFile, err := os.Open(inFileName)
-	if err != nil {
-		return fmt.Errorf("Unable to open file %s: %v", inFileName, err)
+// showBoard writes the board as it stands to the solve's output, in the compact layout with -format compact.
+func showBoard() {
+	state := boardState()
+	if opts.answersOnly {
+		state = answersState(state)
 	}
-	for i := 0; i < 9; i++ {
-		var iv [9]int
+	boardWriter()(opts.out, state)
+}
 
-		intToVal := [...]squareVal{blank, one, two, three, four, five, six, seven, eight, nine}
+// boardWriter returns the function that draws a board in the layout the solve options ask for.
+func boardWriter() func(w io.Writer, state [9][9]squareVal) {
+	switch {
+	case opts.traceStrings:
+		return writeLineBoard
+	case opts.compact:
+		return writeCompactBoard
+	case opts.labels:
+		return writeLabelledBoard
+	}
+	return writeTextBoard
+}
 
-		n, err := fmt.Fscanf(inFile, "%d,%d,%d;%d,%d,%d;%d,%d,%d;\n", &iv[0], &iv[1], &iv[2], &iv[3], &iv[4], &iv[5], &iv[6], &iv[7], &iv[8])
-		if err != nil {
-			return fmt.Errorf("Error reading file %s: %v", inFileName, err)
+// writeSideBySide writes left and right to w next to each other, both drawn by draw, with the lines of left padded to the same width
+// and a gap between them, as for a puzzle and its solution on an answer sheet.
+func writeSideBySide(w io.Writer, left, right [9][9]squareVal, draw func(w io.Writer, state [9][9]squareVal)) {
+	var lbuf, rbuf bytes.Buffer
+	draw(&lbuf, left)
+	draw(&rbuf, right)
+	llines := strings.Split(strings.TrimSuffix(lbuf.String(), "\n"), "\n")
+	rlines := strings.Split(strings.TrimSuffix(rbuf.String(), "\n"), "\n")
+	width := 0
+	for _, line := range llines {
+		if n := utf8.RuneCountInString(line); n > width {
+			width = n
 		}
-		if n != 9 {
-			return fmt.Errorf("Insufficient input line %d", i)
+	}
+	// Both boards are drawn the same way, so they have the same number of lines.
+	for k, line := range llines {
+		if rlines[k] == "" {
+			fmt.Fprintln(w, strings.TrimRight(line, " "))
+			continue
 		}
+		fmt.Fprintf(w, "%s%s    %s\n", line, strings.Repeat(" ", width-utf8.RuneCountInString(line)), rlines[k])
+	}
+}
+
+// answersState returns state with the givens taken out, leaving only the squares the solver filled in, and those it could not.
+func answersState(state [9][9]squareVal) [9][9]squareVal {
+	for i := 0; i < 9; i++ {
 		for j := 0; j < 9; j++ {
-			if iv[j] < 0 || iv[j] > 9 {
-				return fmt.Errorf("Invalid input line %d, position %d", i, j)
-			} else {
-				board[i][j].inChan <- updateMsg{intToVal[iv[j]], set, i, j}
-				board[i][j].inChan <- updateMsg{action: pause}
+			if board[i][j].solvedBy == given {
+				state[i][j] = 0
 			}
 		}
 	}
-	return nil
+	return state
 }
 
+// displayBoard prints the board as it stands to stdout, drawn with box characters, which is handy while debugging a technique.  Like
+// boardState, it must only be called while the square monitors are idle.
 func displayBoard() {
-	var valToStr = map[squareVal]string{
-		one:   "1",
-		two:   "2",
-		three: "3",
-		four:  "4",
-		five:  "5",
-		six:   "6",
-		seven: "7",
-		eight: "8",
-		nine:  "9",
-		blank: " ",
+	writeTextBoard(os.Stdout, boardState())
+}
+
+// writeTextBoard writes state to w, drawn with box characters, with the value of each finalized square and the rest left empty.
+func writeTextBoard(w io.Writer, state [9][9]squareVal) {
+	valToStr := map[squareVal]string{blank: " "}
+	for k, sym := range symbols {
+		valToStr[one<<k] = string(sym)
 	}
 	displaySquare := func(v squareVal) (s string) {
 		s = valToStr[v]
@@ -815,28 +1123,80 @@ func displayBoard() {
 		return
 	}
 
-	fmt.Println("\u250F\u2501\u2501\u2501\u252F\u2501\u2501\u2501\u252F\u2501\u2501\u2501\u2533\u2501\u2501\u2501\u252F\u2501\u2501\u2501" +
+	fmt.Fprintln(w, "\u250F\u2501\u2501\u2501\u252F\u2501\u2501\u2501\u252F\u2501\u2501\u2501\u2533\u2501\u2501\u2501\u252F\u2501\u2501\u2501"+
 		"\u252F\u2501\u2501\u2501\u2533\u2501\u2501\u2501\u252F\u2501\u2501\u2501\u252F\u2501\u2501\u2501\u2513")
 	for i := 0; i < 9; i++ {
-		fmt.Printf("\u2503 %s \u2502 %s \u2502 %s \u2503 %s \u2502 %s \u2502 %s \u2503 %s \u2502 %s \u2502 %s \u2503\n",
-			displaySquare(board[i][0].possVal),
-			displaySquare(board[i][1].possVal),
-			displaySquare(board[i][2].possVal),
-			displaySquare(board[i][3].possVal),
-			displaySquare(board[i][4].possVal),
-			displaySquare(board[i][5].possVal),
-			displaySquare(board[i][6].possVal),
-			displaySquare(board[i][7].possVal),
-			displaySquare(board[i][8].possVal))
-		if i == 2 || i == 5 {
-			fmt.Println("\u2523\u2501\u2501\u2501\u253F\u2501\u2501\u2501\u253F\u2501\u2501\u2501\u254B\u2501\u2501\u2501\u253F\u2501\u2501\u2501" +
+		fmt.Fprintf(w, "\u2503 %s \u2502 %s \u2502 %s \u2503 %s \u2502 %s \u2502 %s \u2503 %s \u2502 %s \u2502 %s \u2503\n",
+			displaySquare(state[i][0]),
+			displaySquare(state[i][1]),
+			displaySquare(state[i][2]),
+			displaySquare(state[i][3]),
+			displaySquare(state[i][4]),
+			displaySquare(state[i][5]),
+			displaySquare(state[i][6]),
+			displaySquare(state[i][7]),
+			displaySquare(state[i][8]))
+		if i%blockH == blockH-1 && i < 8 {
+			fmt.Fprintln(w, "\u2523\u2501\u2501\u2501\u253F\u2501\u2501\u2501\u253F\u2501\u2501\u2501\u254B\u2501\u2501\u2501\u253F\u2501\u2501\u2501"+
 				"\u253F\u2501\u2501\u2501\u254B\u2501\u2501\u2501\u253F\u2501\u2501\u2501\u253F\u2501\u2501\u2501\u252B")
 		} else if i == 8 {
-			fmt.Println("\u2517\u2501\u2501\u2501\u2537\u2501\u2501\u2501\u2537\u2501\u2501\u2501\u253B\u2501\u2501\u2501\u2537\u2501\u2501\u2501" +
+			fmt.Fprintln(w, "\u2517\u2501\u2501\u2501\u2537\u2501\u2501\u2501\u2537\u2501\u2501\u2501\u253B\u2501\u2501\u2501\u2537\u2501\u2501\u2501"+
 				"\u2537\u2501\u2501\u2501\u253B\u2501\u2501\u2501\u2537\u2501\u2501\u2501\u2537\u2501\u2501\u2501\u251B")
 		} else {
-			fmt.Println("\u2520\u2500\u2500\u2500\u253C\u2500\u2500\u2500\u253C\u2500\u2500\u2500\u2542\u2500\u2500\u2500\u253C\u2500\u2500" +
+			fmt.Fprintln(w, "\u2520\u2500\u2500\u2500\u253C\u2500\u2500\u2500\u253C\u2500\u2500\u2500\u2542\u2500\u2500\u2500\u253C\u2500\u2500"+
 				"\u2500\u253C\u2500\u2500\u2500\u2542\u2500\u2500\u2500\u253C\u2500\u2500\u2500\u253C\u2500\u2500\u2500\u2528")
 		}
 	}
 }
+
+// writeLabelledBoard writes state to w as writeTextBoard does, with the columns lettered A to I across the top and the rows numbered 1 to
+// 9 down the left, so that a square can be named as on a chess board: E5 is the middle square.
+func writeLabelledBoard(w io.Writer, state [9][9]squareVal) {
+	var buf bytes.Buffer
+	writeTextBoard(&buf, state)
+	fmt.Fprintln(w, "    A   B   C   D   E   F   G   H   I")
+	for k, line := range strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n") {
+		// The lines of the board alternate between rules and rows of squares, starting and ending with a rule.
+		if k%2 == 1 {
+			fmt.Fprintf(w, "%d %s\n", k/2+1, line)
+		} else {
+			fmt.Fprintf(w, "  %s\n", line)
+		}
+	}
+}
+
+// writeCompactBoard writes state to w as nine lines of nine symbols, with . for a square not yet finalized and a space between
+// the blocks of each row, after an empty line to separate it from the one before.  Each line is 11 columns wide.
+func writeCompactBoard(w io.Writer, state [9][9]squareVal) {
+	fmt.Fprintln(w)
+	for i := 0; i < 9; i++ {
+		var line []rune
+		for j := 0; j < 9; j++ {
+			if j > 0 && j%blockW == 0 {
+				line = append(line, ' ')
+			}
+			if v := state[i][j]; v.IsSingle() {
+				line = append(line, symbols[v.Values()[0]-1])
+			} else {
+				line = append(line, '.')
+			}
+		}
+		fmt.Fprintln(w, string(line))
+	}
+}
+
+// writeLineBoard writes state to w as one line of the 81 squares in row order, with . for a square not yet finalized, as -grid takes
+// a puzzle.
+func writeLineBoard(w io.Writer, state [9][9]squareVal) {
+	line := make([]rune, 0, 81)
+	for i := 0; i < 9; i++ {
+		for j := 0; j < 9; j++ {
+			if v := state[i][j]; v.IsSingle() {
+				line = append(line, symbols[v.Values()[0]-1])
+			} else {
+				line = append(line, '.')
+			}
+		}
+	}
+	fmt.Fprintln(w, string(line))
+}
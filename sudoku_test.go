@@ -0,0 +1,463 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// setBoard sets the board to state, finalizing the squares with one value left, as the analysis would find it between rounds.
+func setBoard(state [9][9]squareVal) {
+	for i := 0; i < 9; i++ {
+		for j := 0; j < 9; j++ {
+			board[i][j].possVal = state[i][j]
+			board[i][j].isFinal = state[i][j].IsSingle()
+		}
+	}
+}
+
+// solvedState returns a completed grid, as the state of a finished board.
+func solvedState(t *testing.T) (state [9][9]squareVal) {
+	t.Helper()
+	solutions := AllSolutions([9][9]int{}, 1)
+	if len(solutions) != 1 {
+		t.Fatalf("the search found no completed grid")
+	}
+	for i := 0; i < 9; i++ {
+		for j := 0; j < 9; j++ {
+			state[i][j] = one << (solutions[0][i][j] - 1)
+		}
+	}
+	return
+}
+
+func TestInspectFinishedBoard(t *testing.T) {
+	setBoard(solvedState(t))
+	noSolution.Store(false)
+	for k := 0; k < 9; k++ {
+		if msgs := inspectRow(k, k); len(msgs) != 0 {
+			t.Errorf("row %d of a finished board gave %d messages", k+1, len(msgs))
+		}
+		if msgs := inspectCol(k, k); len(msgs) != 0 {
+			t.Errorf("column %d of a finished board gave %d messages", k+1, len(msgs))
+		}
+		if msgs := inspectBlock(k); len(msgs) != 0 {
+			t.Errorf("block %d of a finished board gave %d messages", k+1, len(msgs))
+		}
+	}
+	if noSolution.Load() {
+		t.Errorf("a finished board was found to have no solution: %v", noSolutionError())
+	}
+}
+
+// TestAnalyseStalledBoard checks that once a solve has stalled, analysing any unit again reports nothing, although the techniques
+// still deduce the values they cleared in earlier rounds.
+func TestAnalyseStalledBoard(t *testing.T) {
+	grid, _, err := readBoard("AlternatingChain1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	solve(grid, solveOptions{out: io.Discard})
+	if !stalled || noSolution.Load() {
+		t.Fatalf("AlternatingChain1 did not stall without -advanced")
+	}
+	repeated := 0
+	for _, msg := range analysisMsgs() {
+		if found := analyse(msg); len(found) != 0 {
+			t.Errorf("analysing the stalled board gave %d messages, the first %+v", len(found), found[0])
+		}
+		switch msg.action {
+		case analyseRow:
+			repeated += len(inspectRow(msg.destR, msg.destC))
+		case analyseCol:
+			repeated += len(inspectCol(msg.destR, msg.destC))
+		case analyseBlock:
+			repeated += len(inspectBlock(int(msg.val)))
+		}
+	}
+	if repeated == 0 {
+		t.Errorf("the stalled board gave no deductions to filter, so the test shows nothing")
+	}
+}
+
+// analysedUnits counts how many times msgs have each row, column, block and diagonal analysed, as the square monitors read them.
+func analysedUnits(msgs []updateMsg) (rows, cols, blocks [9]int, diags [2]int) {
+	for _, msg := range msgs {
+		switch msg.action {
+		case analyseRow:
+			rows[msg.destR]++
+		case analyseCol:
+			cols[msg.destC]++
+		case analyseBlock:
+			blocks[msg.val]++
+		case analyseDiagonal:
+			if msg.destC == 0 {
+				diags[0]++
+			} else {
+				diags[1]++
+			}
+		}
+	}
+	return
+}
+
+func TestAnalysisMsgsCoverEachUnitOnce(t *testing.T) {
+	defer func() { xVariant = false }()
+	for _, x := range []bool{false, true} {
+		xVariant = x
+		msgs := analysisMsgs()
+		rows, cols, blocks, diags := analysedUnits(msgs)
+		for k := 0; k < 9; k++ {
+			if rows[k] != 1 || cols[k] != 1 || blocks[k] != 1 {
+				t.Errorf("x=%v: row %d, column %d and block %d are analysed %d, %d and %d times a round", x, k+1, k+1, k+1, rows[k],
+					cols[k], blocks[k])
+			}
+		}
+		want, n := [2]int{}, 27
+		if x {
+			want, n = [2]int{1, 1}, 29
+		}
+		if diags != want {
+			t.Errorf("x=%v: the diagonals are analysed %d and %d times a round", x, diags[0], diags[1])
+		}
+		if len(msgs) != n {
+			t.Errorf("x=%v: there are %d analysis messages, not %d", x, len(msgs), n)
+		}
+	}
+}
+
+func TestStateHash(t *testing.T) {
+	state := solvedState(t)
+	state[4][4] = blank
+	copied := state
+	if stateHash(state) != stateHash(copied) || !sameState(state, copied) {
+		t.Fatalf("two equal boards have different hashes, or are not the same")
+	}
+	for i := 0; i < 9; i++ {
+		for j := 0; j < 9; j++ {
+			for val := one; val <= nine; val <<= 1 {
+				changed := state
+				changed[i][j] ^= val
+				if stateHash(changed) == stateHash(state) {
+					t.Errorf("changing %s at R%dC%d leaves the hash the same", valuesString(val), i+1, j+1)
+				}
+				if sameState(changed, state) {
+					t.Errorf("changing %s at R%dC%d leaves the board the same", valuesString(val), i+1, j+1)
+				}
+			}
+		}
+	}
+}
+
+func TestUnchangedStateCollision(t *testing.T) {
+	state := solvedState(t)
+	state[0][0] = blank
+	changed := state
+	changed[0][0] = changed[0][0].Remove(one)
+	hash := stateHash(state)
+	if !unchangedState(state, hash, state, hash) {
+		t.Errorf("a board is not the same as itself")
+	}
+	// Give the changed board the same hash, as a collision would.
+	if unchangedState(changed, hash, state, hash) {
+		t.Errorf("two different boards with the same hash are taken as the same")
+	}
+	if unchangedState(state, hash, state, hash+1) {
+		t.Errorf("two boards with different hashes are taken as the same")
+	}
+}
+
+func TestSquareVal(t *testing.T) {
+	for _, tc := range []struct {
+		v      squareVal
+		count  int
+		single bool
+		values []int
+	}{
+		{0, 0, false, []int{}},
+		{one, 1, true, []int{1}},
+		{nine, 1, true, []int{9}},
+		{two | five | nine, 3, false, []int{2, 5, 9}},
+		{blank, 9, false, []int{1, 2, 3, 4, 5, 6, 7, 8, 9}},
+	} {
+		if n := tc.v.Count(); n != tc.count {
+			t.Errorf("%09b: Count is %d, not %d", tc.v, n, tc.count)
+		}
+		if s := tc.v.IsSingle(); s != tc.single {
+			t.Errorf("%09b: IsSingle is %v", tc.v, s)
+		}
+		if vals := tc.v.Values(); fmt.Sprint(vals) != fmt.Sprint(tc.values) {
+			t.Errorf("%09b: Values are %v, not %v", tc.v, vals, tc.values)
+		}
+	}
+
+	for _, tc := range []struct {
+		v, val squareVal
+		has    bool
+	}{
+		{one | three, one, true},
+		{one | three, two, false},
+		{one | three, one | three, true},
+		// Has is true if v holds any of the values, not only all of them.
+		{one | three, one | two, true},
+		{one, one | two | three, true},
+		{one | three, two | four, false},
+		{one | three, 0, false},
+		{0, blank, false},
+	} {
+		if has := tc.v.Has(tc.val); has != tc.has {
+			t.Errorf("%09b.Has(%09b) is %v, not %v", tc.v, tc.val, has, tc.has)
+		}
+	}
+
+	for _, tc := range []struct {
+		v, val, added, removed squareVal
+	}{
+		{one | three, two, one | two | three, one | three},
+		{one | three, three, one | three, one},
+		{one | three, one | three, one | three, 0},
+		{0, blank, blank, 0},
+		{blank, two | four, blank, blank &^ (two | four)},
+	} {
+		if added := tc.v.Add(tc.val); added != tc.added {
+			t.Errorf("%09b.Add(%09b) is %09b, not %09b", tc.v, tc.val, added, tc.added)
+		}
+		if removed := tc.v.Remove(tc.val); removed != tc.removed {
+			t.Errorf("%09b.Remove(%09b) is %09b, not %09b", tc.v, tc.val, removed, tc.removed)
+		}
+	}
+}
+
+func TestSquareValEach(t *testing.T) {
+	for _, v := range []squareVal{0, one, nine, two | five | nine, blank} {
+		var got squareVal
+		last := squareVal(0)
+		n := 0
+		v.each(func(val squareVal) {
+			if !val.IsSingle() {
+				t.Errorf("%09b: each gave %09b, which is not a single value", v, val)
+			}
+			if val <= last {
+				t.Errorf("%09b: each gave %09b after %09b, out of order", v, val, last)
+			}
+			if got.Has(val) {
+				t.Errorf("%09b: each gave %09b twice", v, val)
+			}
+			got, last = got.Add(val), val
+			n++
+		})
+		if got != v || n != v.Count() {
+			t.Errorf("%09b: each gave %d values making %09b", v, n, got)
+		}
+	}
+}
+
+// subsetKey is a square, and the technique that cleared values from it, for subsetClears.
+type subsetKey struct {
+	r, c   int
+	reason technique
+}
+
+// subsetClears sets square j of row, column or block rcb to vals[j], and every other square of the board to hold every value, and
+// returns the values checkConstrainedSquares and checkConstrainedValues clear from each square of the unit, by technique.
+func subsetClears(t *testing.T, isRCB rcbSelect, rcb int, vals [9]squareVal) map[subsetKey]squareVal {
+	t.Helper()
+	var state [9][9]squareVal
+	for i := 0; i < 9; i++ {
+		for j := 0; j < 9; j++ {
+			state[i][j] = blank
+		}
+	}
+	for j, v := range vals {
+		r, c := rcbSquare(rcb, isRCB, j)
+		state[r][c] = v
+	}
+	setBoard(state)
+	// Work out where each value can go, and which are still unplaced, as inspectRow, inspectCol and inspectBlock do.
+	pos := make(map[squareVal][]int)
+	unplaced := blank
+	for val := one; val <= nine; val <<= 1 {
+		for j, v := range vals {
+			if v.Has(val) {
+				pos[val] = append(pos[val], j)
+			}
+		}
+		if len(pos[val]) == 1 {
+			unplaced = unplaced.Remove(val)
+		}
+	}
+	clears := map[subsetKey]squareVal{}
+	for _, msg := range append(checkConstrainedSquares(unplaced, rcb, isRCB, pos), checkConstrainedValues(rcb, isRCB)...) {
+		if msg.action != clear {
+			t.Fatalf("a subset search sent %+v, which is not a clear", msg)
+		}
+		k := subsetKey{msg.destR, msg.destC, msg.reason}
+		clears[k] = clears[k].Add(msg.val)
+	}
+	return clears
+}
+
+// TestSubsets checks the naked and hidden pairs, triples and quads found in one row, column and block.  The squares not listed hold
+// every value, except as the case says.
+func TestSubsets(t *testing.T) {
+	v := func(vals ...int) (sv squareVal) {
+		for _, n := range vals {
+			sv = sv.Add(one << (n - 1))
+		}
+		return
+	}
+	fill := func(rest squareVal, first ...squareVal) (vals [9]squareVal) {
+		for j := range vals {
+			vals[j] = rest
+		}
+		copy(vals[:], first)
+		return
+	}
+	// want lists the clears expected, as a technique, the squares of the unit and the values cleared from each.
+	type clears struct {
+		reason  technique
+		squares []int
+		vals    squareVal
+	}
+	others := func(from int) (js []int) {
+		for j := from; j < 9; j++ {
+			js = append(js, j)
+		}
+		return
+	}
+	for _, tc := range []struct {
+		name string
+		vals [9]squareVal
+		want []clears
+	}{
+		{"naked pair", fill(blank, v(1, 2), v(1, 2)), []clears{{nakedPair, others(2), v(1, 2)}}},
+		{"naked triple", fill(blank, v(1, 2), v(2, 3), v(1, 3)), []clears{{nakedTriple, others(3), v(1, 2, 3)}}},
+		// A naked triple may take in the squares of a naked pair, which are not left out of the larger search.
+		{"naked triple holding a naked pair", fill(blank, v(1, 2), v(1, 2), v(1, 3)),
+			[]clears{{nakedPair, others(2), v(1, 2)}, {nakedTriple, others(3), v(1, 2, 3)}}},
+		{"naked quad", fill(blank, v(1, 2), v(2, 3), v(3, 4), v(1, 4)), []clears{{nakedQuad, others(4), v(1, 2, 3, 4)}}},
+		{"hidden pair", fill(blank.Remove(v(1, 2)), blank, blank), []clears{{hiddenPair, []int{0, 1}, blank.Remove(v(1, 2))}}},
+		{"hidden triple", fill(blank.Remove(v(1, 2, 3)), blank.Remove(v(2)), blank.Remove(v(3)), blank.Remove(v(1))),
+			[]clears{{hiddenTriple, []int{0, 1, 2}, v(4, 5, 6, 7, 8, 9)}}},
+		// 4 has only the one place left, so it is a hidden single, and is not taken into a hidden triple with the pair.
+		{"hidden pair beside a hidden single", fill(blank.Remove(v(1, 2, 4)), blank.Remove(v(4)), blank.Remove(v(4)),
+			blank.Remove(v(1, 2))),
+			[]clears{{hiddenPair, []int{0, 1}, v(3, 4, 5, 6, 7, 8, 9)}}},
+		{"hidden quad", fill(blank.Remove(v(1, 2, 3, 4)), blank.Remove(v(2, 3)), blank.Remove(v(3, 4)), blank.Remove(v(1, 4)),
+			blank.Remove(v(1, 2))), []clears{{hiddenQuad, []int{0, 1, 2, 3}, v(5, 6, 7, 8, 9)}}},
+	} {
+		for _, unit := range []struct {
+			name  string
+			isRCB rcbSelect
+			rcb   int
+		}{{"row 4", row, 3}, {"column 7", column, 6}, {"block 5", block, 4}} {
+			want := map[subsetKey]squareVal{}
+			for _, w := range tc.want {
+				for _, j := range w.squares {
+					r, c := rcbSquare(unit.rcb, unit.isRCB, j)
+					want[subsetKey{r, c, w.reason}] = w.vals
+				}
+			}
+			got := subsetClears(t, unit.isRCB, unit.rcb, tc.vals)
+			for k, vals := range got {
+				if want[k] != vals {
+					t.Errorf("%s in %s: %s clears %s from R%dC%d, not %s", tc.name, unit.name, k.reason, valuesString(vals), k.r+1, k.c+1,
+						valuesString(want[k]))
+				}
+			}
+			for k, vals := range want {
+				if _, ok := got[k]; !ok {
+					t.Errorf("%s in %s: %s does not clear %s from R%dC%d", tc.name, unit.name, k.reason, valuesString(vals), k.r+1, k.c+1)
+				}
+			}
+		}
+	}
+}
+
+// TestSolveReproducible solves each puzzle many times over and checks that every solve prints the same boards and makes the same
+// deductions, with the same techniques, in the same order, however the square monitors happen to be scheduled.  The solves are spread
+// over several threads, and over different numbers of monitors, so that the messages of a phase reach the round looper in a different
+// order from one solve to the next.
+func TestSolveReproducible(t *testing.T) {
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(4))
+	defer func() { monitors = 9 }()
+	for _, name := range []string{"FriNov27-2020", "Swordfish", "Skyscraper", "XYZWing", "RemotePair"} {
+		grid, _, err := readBoard(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var wantBoards, wantEvents string
+		for n := 0; n < 20; n++ {
+			monitors = []int{9, 1, 3, 27, 81}[n%5]
+			var boards, events strings.Builder
+			solve(grid, solveOptions{showRounds: true, out: &boards})
+			if err := writeEvents(&events); err != nil {
+				t.Fatal(err)
+			}
+			if n == 0 {
+				wantBoards, wantEvents = boards.String(), events.String()
+				if !strings.Contains(wantEvents, `"technique"`) {
+					t.Fatalf("%s: the solve made no deductions", name)
+				}
+				continue
+			}
+			if boards.String() != wantBoards {
+				t.Errorf("%s: solve %d printed different boards from the first", name, n+1)
+			}
+			if events.String() != wantEvents {
+				t.Errorf("%s: solve %d made different deductions, or made them in a different order, from the first", name, n+1)
+			}
+		}
+	}
+}
+
+// benchPuzzles are the plain puzzles of the repository that BenchmarkSolve times.
+var benchPuzzles = []string{"FriDec4-2020", "FriNov13-2020", "FriNov27-2020", "FriNov6-2020", "MonNov16-2020", "MonNov2-2020",
+	"SatNov28-2020", "XWing", "XWing2", "Swordfish", "Jellyfish", "Skyscraper", "EmptyRectangle", "XYZWing", "RemotePair"}
+
+// TestMonitorsAgree checks that, however many square monitors own the squares, each puzzle is solved round by round to the same boards.
+func TestMonitorsAgree(t *testing.T) {
+	defer func() { monitors = 9 }()
+	for _, name := range benchPuzzles {
+		grid, _, err := readBoard(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var want string
+		for _, m := range []int{9, 1, 3, 27, 81} {
+			monitors = m
+			var out strings.Builder
+			solve(grid, solveOptions{showRounds: true, out: &out})
+			if m == 9 {
+				want = out.String()
+			} else if out.String() != want {
+				t.Errorf("%s: %d monitors solve it differently from 9", name, m)
+			}
+		}
+	}
+}
+
+// BenchmarkSolve times solving the plain puzzles of the repository, for comparing changes to the square monitors and the round looper.
+func BenchmarkSolve(b *testing.B) {
+	var grids [][9][9]int
+	for _, name := range benchPuzzles {
+		grid, _, err := readBoard(name)
+		if err != nil {
+			b.Fatal(err)
+		}
+		grids = append(grids, grid)
+	}
+	defer func() { monitors = 9 }()
+	for _, m := range []int{1, 3, 9, 27, 81} {
+		b.Run(fmt.Sprintf("monitors=%d", m), func(b *testing.B) {
+			monitors = m
+			for n := 0; n < b.N; n++ {
+				for _, grid := range grids {
+					solve(grid, solveOptions{out: io.Discard})
+				}
+			}
+		})
+	}
+}
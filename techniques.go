@@ -0,0 +1,451 @@
+// techniques.go
+//
+// Solving techniques that look at more than one row, column or block at a time.  Unlike the row, column and block analysis, which is
+// handed out to 27 of the square monitors, these run in the round looper itself, after the analysis phase of a round has completed and
+// while every square monitor is idle.  Like everything else, they only read the board, and send the clear messages they deduce to the
+// buffer channel to be forwarded in the next phase of the round.
+package main
+
+import (
+	"math/bits"
+	"sort"
+)
+
+const (
+	emptyRectangle technique = "empty-rectangle"
+	skyscraper     technique = "skyscraper"
+	xWing          technique = "x-wing"
+	swordfish      technique = "swordfish"
+	jellyfish      technique = "jellyfish"
+	xyWing         technique = "xy-wing"
+	xyzWing        technique = "xyz-wing"
+	remotePair     technique = "remote-pair"
+)
+
+var fishNames = map[int]technique{2: xWing, 3: swordfish, 4: jellyfish}
+
+// gridTechnique is one of the techniques inspectGrid runs, and the name its deductions are made under.
+type gridTechnique struct {
+	name  technique
+	check func()
+}
+
+// gridTechniques are the techniques inspectGrid runs, in the order it runs them unless techniqueOrder says otherwise.  Those registered
+// with RegisterTechnique are added at the end.
+var gridTechniques = []gridTechnique{
+	{emptyRectangle, func() { blank.each(checkEmptyRectangles) }},
+	{skyscraper, func() { blank.each(checkSkyscrapers) }},
+	{xWing, func() { blank.each(func(val squareVal) { findFish(val, 2) }) }},
+	{swordfish, func() { blank.each(func(val squareVal) { findFish(val, 3) }) }},
+	{jellyfish, func() { blank.each(func(val squareVal) { findFish(val, 4) }) }},
+	{xyWing, checkXYWings},
+	{xyzWing, checkXYZWings},
+	{remotePair, checkRemotePairs},
+	{cageSum, func() {
+		if len(cages) > 0 {
+			checkCages()
+		}
+	}},
+	{alignedPairExclusion, checkAlignedPairs},
+	{sueDeCoq, checkSueDeCoq},
+	{alternatingInferenceChain, checkAlternatingInferenceChains},
+}
+
+// techniqueOrder holds the techniques named by -technique-order, in the order given.  Every technique looks at the same board within a
+// phase, and what they find is only applied in the next, so the order cannot change the board a round reaches.  It decides the order
+// the grid techniques run in, and which technique a deduction is credited to when more than one makes it: the messages of a phase are
+// applied in this order, and the first to change a square is the one recorded.  The techniques it leaves out come after it, the grid
+// techniques in the order of gridTechniques and the messages by name.
+var techniqueOrder []technique
+
+// techniqueRank returns the place of t in techniqueOrder, or len(techniqueOrder) if it is not there.
+func techniqueRank(t technique) int {
+	for k, o := range techniqueOrder {
+		if o == t {
+			return k
+		}
+	}
+	return len(techniqueOrder)
+}
+
+func inspectGrid() {
+	ordered := append([]gridTechnique(nil), gridTechniques...)
+	sort.SliceStable(ordered, func(a, b int) bool { return techniqueRank(ordered[a].name) < techniqueRank(ordered[b].name) })
+	for _, gt := range ordered {
+		// A disabled technique's deductions would only be dropped by bufferMsg, so it is not run at all.
+		if disabled[gt.name] || (isAdvanced(gt.name) && !advanced) {
+			continue
+		}
+		gt.check()
+	}
+}
+
+func seesSquare(r1, c1, r2, c2 int) bool {
+	if r1 == r2 && c1 == c2 {
+		return false
+	}
+	return r1 == r2 || c1 == c2 || blockOf[r1][c1] == blockOf[r2][c2]
+}
+
+type gridPos struct {
+	r int
+	c int
+}
+
+func squaresWithCount(n int) (squares []gridPos) {
+	for i := 0; i < 9; i++ {
+		for j := 0; j < 9; j++ {
+			if !board[i][j].isFinal && board[i][j].possVal.Count() == n {
+				squares = append(squares, gridPos{i, j})
+			}
+		}
+	}
+	return
+}
+
+func clearIfPossible(val squareVal, r, c int, reason technique) {
+	if !board[r][c].isFinal && board[r][c].possVal.Has(val) {
+		bufferMsg(updateMsg{val, clear, r, c, reason})
+	}
+}
+
+// rowCands returns the columns of row r where val is still possible.
+func rowCands(val squareVal, r int) (cols []int) {
+	for j := 0; j < 9; j++ {
+		if board[r][j].possVal.Has(val) {
+			cols = append(cols, j)
+		}
+	}
+	return
+}
+
+// colCands returns the rows of column c where val is still possible.
+func colCands(val squareVal, c int) (rows []int) {
+	for i := 0; i < 9; i++ {
+		if board[i][c].possVal.Has(val) {
+			rows = append(rows, i)
+		}
+	}
+	return
+}
+
+func checkEmptyRectangles(val squareVal) {
+	// If the possible locations of a value within a block all lie on one row and one column of that block, then the value is in that
+	// row or in that column of the block.  Pair that with a row or column that has only two places for the value, one of them on the
+	// block's row (or column), and the square that sees both the far end of that pair and the block's column (or row) cannot be the value.
+	for b := 0; b < 9; b++ {
+		cnt, placed := 0, false
+		var inRow, inCol [9]bool
+		for _, p := range blockSquares[b] {
+			inRow[p.r], inCol[p.c] = true, true
+			if board[p.r][p.c].possVal.Has(val) {
+				cnt++
+				placed = placed || board[p.r][p.c].isFinal
+			}
+		}
+		if placed || cnt < 2 {
+			// Either already placed in this block, or a single that the block analysis will place.
+			continue
+		}
+		for erR := 0; erR < 9; erR++ {
+			if !inRow[erR] {
+				continue
+			}
+		nextCol:
+			for erC := 0; erC < 9; erC++ {
+				if !inCol[erC] {
+					continue
+				}
+				for _, p := range blockSquares[b] {
+					if p.r != erR && p.c != erC && board[p.r][p.c].possVal.Has(val) {
+						continue nextCol
+					}
+				}
+				// The value is confined to row erR and column erC within this block.  Look for a conjugate pair in a column whose
+				// end on row erR is outside the block.  With irregular blocks, row erR and column erC can cross outside the block,
+				// so column erC itself has to be passed over.
+				for c := 0; c < 9; c++ {
+					if c == erC || blockOf[erR][c] == b {
+						continue
+					}
+					if rows := colCands(val, c); len(rows) == 2 && (rows[0] == erR || rows[1] == erR) {
+						far := rows[0]
+						if far == erR {
+							far = rows[1]
+						}
+						if blockOf[far][erC] != b {
+							clearIfPossible(val, far, erC, emptyRectangle)
+						}
+					}
+				}
+				// And the same for a conjugate pair in a row with an end on column erC outside the block.
+				for r := 0; r < 9; r++ {
+					if r == erR || blockOf[r][erC] == b {
+						continue
+					}
+					if cols := rowCands(val, r); len(cols) == 2 && (cols[0] == erC || cols[1] == erC) {
+						far := cols[0]
+						if far == erC {
+							far = cols[1]
+						}
+						if blockOf[erR][far] != b {
+							clearIfPossible(val, erR, far, emptyRectangle)
+						}
+					}
+				}
+			}
+		}
+	}
+}
+
+func checkXYWings() {
+	// A pivot square holding only XY that sees one square holding only XZ and another holding only YZ.  Whichever value the pivot
+	// takes, one of those two pincers must be Z, so Z can be cleared from every square that sees both pincers.
+	pairs := squaresWithCount(2)
+	for _, p := range pairs {
+		pv := board[p.r][p.c].possVal
+		for ia, a := range pairs {
+			av := board[a.r][a.c].possVal
+			if av == pv || !seesSquare(p.r, p.c, a.r, a.c) || (av&pv).Count() != 1 {
+				continue
+			}
+			for _, b := range pairs[ia+1:] {
+				bv := board[b.r][b.c].possVal
+				if bv == pv || bv == av || !seesSquare(p.r, p.c, b.r, b.c) || av&bv&pv != 0 {
+					continue
+				}
+				z := av & bv
+				if z.Count() != 1 || (av|bv|pv).Count() != 3 {
+					continue
+				}
+				var targets []gridPos
+				for i := 0; i < 9; i++ {
+					for j := 0; j < 9; j++ {
+						if seesSquare(i, j, a.r, a.c) && seesSquare(i, j, b.r, b.c) && !(i == p.r && j == p.c) {
+							targets = append(targets, gridPos{i, j})
+						}
+					}
+				}
+				noteChain(xyWing, z, []gridPos{p, a, b}, []int{1, 2, 2}, targets)
+				for _, t := range targets {
+					clearIfPossible(z, t.r, t.c, xyWing)
+				}
+			}
+		}
+	}
+}
+
+func checkXYZWings() {
+	// As with the XY-Wing, but the pivot also holds Z, so it holds XYZ.  Now the pivot itself may be Z, and Z can only be cleared
+	// from squares that see the pivot and both pincers.
+	pairs := squaresWithCount(2)
+	for _, p := range squaresWithCount(3) {
+		pv := board[p.r][p.c].possVal
+		for ia, a := range pairs {
+			av := board[a.r][a.c].possVal
+			if av&^pv != 0 || !seesSquare(p.r, p.c, a.r, a.c) {
+				continue
+			}
+			for _, b := range pairs[ia+1:] {
+				bv := board[b.r][b.c].possVal
+				if bv&^pv != 0 || bv == av || !seesSquare(p.r, p.c, b.r, b.c) {
+					continue
+				}
+				z := av & bv
+				var targets []gridPos
+				for i := 0; i < 9; i++ {
+					for j := 0; j < 9; j++ {
+						if seesSquare(i, j, p.r, p.c) && seesSquare(i, j, a.r, a.c) && seesSquare(i, j, b.r, b.c) {
+							targets = append(targets, gridPos{i, j})
+						}
+					}
+				}
+				noteChain(xyzWing, z, []gridPos{p, a, b}, []int{1, 2, 2}, targets)
+				for _, t := range targets {
+					clearIfPossible(z, t.r, t.c, xyzWing)
+				}
+			}
+		}
+	}
+}
+
+func checkSkyscrapers(val squareVal) {
+	// Two rows that each have only two places for a value, with one of those places in the same column.  The value cannot be in both
+	// squares of that shared column, so it is in at least one of the other two ends, and can be cleared from every square that sees
+	// both ends.  The same holds with rows and columns swapped.
+	for r1 := 0; r1 < 9; r1++ {
+		cols1 := rowCands(val, r1)
+		if len(cols1) != 2 {
+			continue
+		}
+		for r2 := r1 + 1; r2 < 9; r2++ {
+			cols2 := rowCands(val, r2)
+			if len(cols2) != 2 {
+				continue
+			}
+			for k1 := 0; k1 < 2; k1++ {
+				for k2 := 0; k2 < 2; k2++ {
+					if cols1[k1] != cols2[k2] || cols1[1-k1] == cols2[1-k2] {
+						continue
+					}
+					end1, end2 := gridPos{r1, cols1[1-k1]}, gridPos{r2, cols2[1-k2]}
+					for i := 0; i < 9; i++ {
+						for j := 0; j < 9; j++ {
+							if seesSquare(i, j, end1.r, end1.c) && seesSquare(i, j, end2.r, end2.c) {
+								clearIfPossible(val, i, j, skyscraper)
+							}
+						}
+					}
+				}
+			}
+		}
+	}
+	for c1 := 0; c1 < 9; c1++ {
+		rows1 := colCands(val, c1)
+		if len(rows1) != 2 {
+			continue
+		}
+		for c2 := c1 + 1; c2 < 9; c2++ {
+			rows2 := colCands(val, c2)
+			if len(rows2) != 2 {
+				continue
+			}
+			for k1 := 0; k1 < 2; k1++ {
+				for k2 := 0; k2 < 2; k2++ {
+					if rows1[k1] != rows2[k2] || rows1[1-k1] == rows2[1-k2] {
+						continue
+					}
+					end1, end2 := gridPos{rows1[1-k1], c1}, gridPos{rows2[1-k2], c2}
+					for i := 0; i < 9; i++ {
+						for j := 0; j < 9; j++ {
+							if seesSquare(i, j, end1.r, end1.c) && seesSquare(i, j, end2.r, end2.c) {
+								clearIfPossible(val, i, j, skyscraper)
+							}
+						}
+					}
+				}
+			}
+		}
+	}
+}
+
+func findFish(val squareVal, size int) {
+	// A fish of the given size is that many rows in which the only places left for a value all lie within the same number of columns.
+	// Each of the rows must hold the value in one of those columns, which uses up the value in all of the columns, so it can be cleared
+	// from those columns in every other row.  The same holds with rows and columns swapped.  Size 2 is the X-Wing, 3 the Swordfish and
+	// 4 the Jellyfish.
+	var rowMask, colMask [9]uint16
+	for i := 0; i < 9; i++ {
+		for j := 0; j < 9; j++ {
+			if board[i][j].possVal.Has(val) {
+				if board[i][j].isFinal {
+					// Already placed; neither its row nor its column can be part of the pattern.
+					rowMask[i], colMask[j] = 0x1FF, 0x1FF
+					continue
+				}
+				rowMask[i] |= 1 << j
+				colMask[j] |= 1 << i
+			}
+		}
+	}
+
+	// search picks size base lines from those with the value in at most size places, and when their places all lie within size cover
+	// lines, clears the value from the cover lines everywhere off the base lines.
+	search := func(mask [9]uint16, clearAt func(line, cross int)) {
+		var lines []int
+		for k := 0; k < 9; k++ {
+			if n := bits.OnesCount16(mask[k]); n >= 2 && n <= size {
+				lines = append(lines, k)
+			}
+		}
+		var base uint16
+		var choose func(from, chosen int, cover uint16)
+		choose = func(from, chosen int, cover uint16) {
+			if bits.OnesCount16(cover) > size {
+				return
+			}
+			if chosen == size {
+				for line := 0; line < 9; line++ {
+					if base&(1<<line) != 0 {
+						continue
+					}
+					for cross := 0; cross < 9; cross++ {
+						if cover&(1<<cross) != 0 {
+							clearAt(line, cross)
+						}
+					}
+				}
+				return
+			}
+			for k := from; k < len(lines); k++ {
+				base |= 1 << lines[k]
+				choose(k+1, chosen+1, cover|mask[lines[k]])
+				base &^= 1 << lines[k]
+			}
+		}
+		choose(0, 0, 0)
+	}
+	search(rowMask, func(r, c int) { clearIfPossible(val, r, c, fishNames[size]) })
+	search(colMask, func(c, r int) { clearIfPossible(val, r, c, fishNames[size]) })
+}
+
+func checkRemotePairs() {
+	// A chain of squares that all hold only the same two values XY, each seeing the next.  Along the chain the squares alternate
+	// between X and Y, so any two squares an odd number of links apart hold one each, and a square that sees both can hold neither.
+	// Colouring each chain's squares by the parity of their distance along it finds every such pair at once.  A chain of two is a
+	// naked pair, which the row, column and block analysis already finds, but it does no harm to find it again here.
+	pairs := squaresWithCount(2)
+	colour := make([]int, len(pairs))
+	for start := range pairs {
+		if colour[start] != 0 {
+			continue
+		}
+		xy := board[pairs[start].r][pairs[start].c].possVal
+		// Colour the chain through start with 1 and 2, breadth first.
+		chain := []int{start}
+		colour[start] = 1
+		consistent := true
+		for k := 0; k < len(chain); k++ {
+			a := pairs[chain[k]]
+			for b := range pairs {
+				if board[pairs[b].r][pairs[b].c].possVal != xy || !seesSquare(a.r, a.c, pairs[b].r, pairs[b].c) {
+					continue
+				}
+				if colour[b] == 0 {
+					colour[b] = 3 - colour[chain[k]]
+					chain = append(chain, b)
+				} else if colour[b] == colour[chain[k]] {
+					// Two squares holding only XY that see each other would have to take the same value; that is for the other
+					// techniques to sort out, so leave this chain alone.
+					consistent = false
+				}
+			}
+		}
+		if !consistent || len(chain) < 2 {
+			continue
+		}
+		var targets []gridPos
+		for i := 0; i < 9; i++ {
+			for j := 0; j < 9; j++ {
+				var seen [3]bool
+				for _, k := range chain {
+					if seesSquare(i, j, pairs[k].r, pairs[k].c) {
+						seen[colour[k]] = true
+					}
+				}
+				if seen[1] && seen[2] {
+					targets = append(targets, gridPos{i, j})
+				}
+			}
+		}
+		nodes := make([]gridPos, len(chain))
+		nodeColour := make([]int, len(chain))
+		for n, k := range chain {
+			nodes[n], nodeColour[n] = pairs[k], colour[k]
+		}
+		noteChain(remotePair, xy, nodes, nodeColour, targets)
+		for _, t := range targets {
+			clearIfPossible(xy, t.r, t.c, remotePair)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+)
+
+// stalledEliminations solves the puzzle in the file name, which must solve, and then again with tech turned off, which must stall.  It
+// returns what PendingEliminations finds on the board that stalled, with tech turned back on, as the square and the values cleared
+// from it, such as R2C5-37, counting rows and columns from 1.  Every other technique has run out on that board, so each of them must be
+// made by tech, and none may clear a value of the solution.  Any techniques already turned off in disabled stay off throughout.
+func stalledEliminations(t *testing.T, name string, tech technique) []string {
+	t.Helper()
+	grid, info, err := readBoard(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	savedCages := cages
+	cages = info.Cages
+	setBlocks(info.Regions)
+	defer func() {
+		cages = savedCages
+		setBlocks(nil)
+		disabled = map[technique]bool{}
+	}()
+
+	off := disabled
+	solve(grid, solveOptions{out: io.Discard})
+	if stalled || noSolution.Load() {
+		t.Fatalf("%s does not solve with %s", name, tech)
+	}
+	solution := boardGrid()
+	disabled = map[technique]bool{tech: true}
+	for k := range off {
+		disabled[k] = true
+	}
+	solve(grid, solveOptions{out: io.Discard})
+	if !stalled || noSolution.Load() {
+		t.Fatalf("%s does not stall without %s", name, tech)
+	}
+	disabled = off
+
+	var elims []string
+	for _, e := range PendingEliminations() {
+		if e.Technique != string(tech) {
+			t.Errorf("%s: %s clears %s from R%dC%d on the board that stalled without %s", name, e.Technique, valuesString(e.Values),
+				e.Row+1, e.Col+1, tech)
+		}
+		if e.Values.Has(one << (solution[e.Row][e.Col] - 1)) {
+			t.Errorf("%s: %s clears the solution, %d, from R%dC%d", name, e.Technique, solution[e.Row][e.Col], e.Row+1, e.Col+1)
+		}
+		elims = append(elims, fmt.Sprintf("R%dC%d-%s", e.Row+1, e.Col+1, valuesString(e.Values)))
+	}
+	return elims
+}
+
+// checkEliminations runs stalledEliminations and checks it finds exactly want, in row and column order.
+func checkEliminations(t *testing.T, name string, tech technique, want ...string) {
+	t.Helper()
+	if got := stalledEliminations(t, name, tech); strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("%s: %s clears %v, not %v", name, tech, got, want)
+	}
+}
+
+func TestXYZWing(t *testing.T) {
+	// The pivot R4C7 holds 157, and sees the pincers R4C3, holding 15, and R6C8, holding 57.  Whichever of the three is 5, R4C9 sees it.
+	checkEliminations(t, "XYZWing", xyzWing, "R4C9-5")
+}
+
+func TestEmptyRectangle(t *testing.T) {
+	// 6 is confined to row 7 and column 5 of the bottom middle block, and row 1 has 6 only in columns 5 and 9, so R7C9 is not 6.
+	// Likewise it is confined to row 3 and column 5 of the top middle block, and column 7 has 6 only in rows 3 and 9, so R9C5 is not 6.
+	checkEliminations(t, "EmptyRectangle", emptyRectangle, "R7C9-6", "R9C5-6")
+}
+
+func TestXWing(t *testing.T) {
+	// 7 can only go in rows 5 and 8 of columns 2 and 4.
+	checkEliminations(t, "XWing2", xWing, "R3C4-7")
+}
+
+func TestSwordfish(t *testing.T) {
+	// 3 can only go in rows 1, 3 and 6 of columns 2, 5 and 7.
+	checkEliminations(t, "Swordfish", swordfish, "R1C1-3", "R1C3-3", "R3C8-3", "R6C8-3")
+}
+
+func TestJellyfish(t *testing.T) {
+	// 7 can only go in rows 1, 2, 5 and 9 of columns 3, 4, 6 and 7.
+	checkEliminations(t, "Jellyfish", jellyfish, "R1C2-7", "R1C5-7", "R2C1-7", "R2C9-7", "R5C1-7", "R5C2-7", "R5C9-7", "R9C2-7",
+		"R9C5-7")
+}
+
+func TestDiagonalPointing(t *testing.T) {
+	xVariant = true
+	defer func() { xVariant = false }()
+	// In the top left block 9 can only go at R2C2 and R3C3, both on the main diagonal.
+	checkEliminations(t, "XSudoku2", diagonalPointing, "R4C4-9")
+}
+
+func TestSkyscraper(t *testing.T) {
+	// Rows 2 and 9 have 1 only in columns 2 and 6 and columns 1 and 6, and R3C1 sees both R2C2 and R9C1.
+	checkEliminations(t, "Skyscraper", skyscraper, "R3C1-1")
+}
+
+func TestRemotePair(t *testing.T) {
+	// R1C8, R9C8, R8C9, R8C3, R7C1 and R2C1 can each only be 4 or 7, and each sees the next.  R2C9 sees R2C1 and R8C9, three links
+	// apart, so one of them is 4 and the other 7.
+	checkEliminations(t, "RemotePair", remotePair, "R2C9-7")
+}
+
+func TestCageSum(t *testing.T) {
+	// Only one cage is worked out here.  With no 5 or 6 left in column 3 of rows 4 to 6, their 17 cage cannot hold 4, since the
+	// other two would have to be 5 and 8 or 6 and 7.
+	checkEliminations(t, "Killer.json", cageSum, "R1C1-79", "R1C2-134789", "R1C4-149", "R1C5-69", "R2C1-9", "R2C2-13789",
+		"R2C4-14579", "R2C5-1467", "R2C6-69", "R3C6-79", "R4C3-4", "R5C3-4", "R6C3-4", "R6C8-18", "R6C9-18", "R7C1-124568",
+		"R7C2-246789", "R7C3-245789", "R7C5-12349", "R7C6-123479", "R8C1-12458", "R8C8-35789", "R8C9-789")
+}
+
+func TestAlignedPairExclusion(t *testing.T) {
+	advanced = true
+	defer func() { advanced = false }()
+	// R6C2 and R6C3 cannot be 1 or 2 with 9, which would leave column 2 of rows 2 to 4 with two of 1, 2, 4 and 9, nor 1 or 2 with 3
+	// or 6, which would leave row 6 of columns 4 to 6 with two of 1, 2, 3 and 6.
+	checkEliminations(t, "AlignedPair", alignedPairExclusion, "R3C7-25", "R6C2-12")
+}
+
+func TestAlternatingInferenceChain(t *testing.T) {
+	advanced = true
+	defer func() { advanced = false }()
+	// A chain from a value back to itself proves it true, so several squares are placed at once, clearing the rest of their values.
+	checkEliminations(t, "AlternatingChain1", alternatingInferenceChain, "R1C1-2", "R1C2-4", "R1C3-3", "R1C4-9", "R1C8-7", "R1C9-25",
+		"R2C2-6", "R2C3-9", "R2C4-2", "R2C8-4", "R2C9-8", "R3C1-5", "R3C7-9", "R3C8-6", "R3C9-2", "R4C2-7", "R4C7-68", "R4C8-89",
+		"R4C9-67", "R5C3-6", "R5C5-7", "R6C5-6", "R6C8-7", "R7C2-3", "R7C3-7", "R7C7-6", "R7C9-8")
+}
+
+func TestSueDeCoq(t *testing.T) {
+	advanced = true
+	defer func() { advanced = false }()
+	// The chains solve the SueDeCoq puzzle on their own, so they are kept off.  Row 8 columns 1 to 3 hold 3, 4, 6, 7, 8 and 9 between
+	// them.  Along with R8C8, 7 or 8, from the rest of the row, and R7C1 and R9C1, 3, 4 or 9, from the rest of the block, six squares
+	// hold six values, so 7 and 8 are cleared from the rest of row 8 and 3, 4 and 9 from the rest of the block.
+	disabled = map[technique]bool{alternatingInferenceChain: true}
+	checkEliminations(t, "SueDeCoq", sueDeCoq, "R7C2-4", "R8C5-8", "R8C7-8")
+}
@@ -0,0 +1,42 @@
+//go:build js && wasm
+
+// wasm.go
+//
+// The entry point for a WebAssembly build, to run the solver in a browser with no server:
+//
+//	GOOS=js GOARCH=wasm go build -o sudoku.wasm
+//
+// Once the module is started with the wasm_exec.js that comes with Go, sudokuSolve(puzzle) can be called from JavaScript.  The puzzle is
+// a string of the 81 squares in row order, as solve -grid takes it, and the result is an object with the board the solver reached, in the
+// same form with . for a square it could not finalize, as solution, and, unless the puzzle was solved, the reason why as error.
+package main
+
+import "syscall/js"
+
+func main() {
+	js.Global().Set("sudokuSolve", js.FuncOf(solveJS))
+	// The solver is only ever called from JavaScript, so main must not return.
+	select {}
+}
+
+func solveJS(this js.Value, args []js.Value) any {
+	if len(args) != 1 || args[0].Type() != js.TypeString {
+		return map[string]any{"error": "sudokuSolve takes one argument, the puzzle as a string of 81 squares"}
+	}
+	grid, err := parseGridString(args[0].String())
+	if err != nil {
+		return map[string]any{"error": err.Error()}
+	}
+	solution, err := Solve(grid)
+	line := make([]rune, 0, 81)
+	for i := 0; i < 9; i++ {
+		for j := 0; j < 9; j++ {
+			line = append(line, squareSymbol(solution[i][j], '.'))
+		}
+	}
+	result := map[string]any{"solution": string(line)}
+	if err != nil {
+		result["error"] = err.Error()
+	}
+	return result
+}